@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	dbtodo "github.com/johnjallday/flow-workspace/internal/db/todo"
+	"github.com/johnjallday/flow-workspace/internal/todo"
+	"github.com/spf13/cobra"
+)
+
+// archiveCmd lists the archived tasks of every project matching a query.
+var archiveCmd = &cobra.Command{
+	Use:   "archive [query]...",
+	Short: "List archived tasks",
+	Long: `List the archived tasks matching the query, most recently completed first.
+The query is made of the terms ` + todo.ArchiveQueryHelp + `.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := todo.ParseArchiveQuery(strings.Join(args, " "), time.Now())
+		if err != nil {
+			return err
+		}
+		conn, err := dbtodo.InitDB(cfg.DBPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		archived, err := todo.QueryArchive(conn, q)
+		if err != nil {
+			return err
+		}
+		return printListing(func() { todo.PrintArchive(archived) }, todo.ArchiveListing(archived))
+	},
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/johnjallday/flow-workspace/internal/config"
+	"github.com/johnjallday/flow-workspace/internal/output"
+	"github.com/johnjallday/flow-workspace/internal/root"
+	"github.com/johnjallday/flow-workspace/internal/startup"
+	"github.com/johnjallday/flow-workspace/internal/todo"
+	"github.com/johnjallday/flow-workspace/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+// Completion functions run without setup, so they load the settings
+// themselves and never create or migrate the database. The scope of the
+// current directory is detected as by repl.StartREPL: project names and
+// aliases come from the projects.toml of the current workspace, workspace
+// names from the current root or else the root_dir of the settings.
+
+// completionSetup loads the settings for a completion function and reports
+// whether that succeeded.
+func completionSetup() bool {
+	var err error
+	if cfg, err = config.Load(); err != nil {
+		return false
+	}
+	cfg.DBPath, err = startup.ResolveDBPath(cfg, dbFlag, profile)
+	return err == nil
+}
+
+// completeTasks completes the IDs of the tasks of the project given with
+// --dir, or the current directory, described by the task description. With
+// open set, finished tasks are left out. Tasks already given are not repeated.
+func completeTasks(open bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if !completionSetup() {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		dir, err := projectDir()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		todos, err := todo.LoadProjectTodos(cfg.DBPath, dir)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		given := make(map[string]bool)
+		for _, arg := range args {
+			given[arg] = true
+		}
+		var comps []string
+		for _, t := range todos {
+			if t.ID == "" || given[t.ID] || (open && !t.CompletedDate.IsZero()) {
+				continue
+			}
+			if strings.HasPrefix(t.ID, toComplete) {
+				comps = append(comps, t.ID+"\t"+t.Description)
+			}
+		}
+		return comps, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeProjects completes the names and aliases of the projects of the
+// current workspace, or directories outside a workspace.
+func completeProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	ws := currentWorkspace()
+	if ws == "" {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	projs, err := workspace.LoadProjectsToml(ws)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	var comps []string
+	for _, proj := range projs.Projects {
+		comps = appendCompletion(comps, toComplete, proj.Name, proj.ProjectType)
+		if proj.Alias != "" {
+			comps = appendCompletion(comps, toComplete, proj.Alias, "alias of "+proj.Name)
+		}
+	}
+	return comps, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeWorkspaces completes the names of the workspaces of the current
+// root, or directories if there is none.
+func completeWorkspaces(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 || !completionSetup() {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	rootDir := currentRoot()
+	if rootDir == "" {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	names, err := root.Workspaces(cfg, rootDir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	var comps []string
+	for _, name := range names {
+		comps = appendCompletion(comps, toComplete, name, "")
+	}
+	return comps, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeDirs completes directories, for arguments such as a root directory.
+func completeDirs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
+
+// completeFormats completes the values of --format.
+func completeFormats(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{output.Table, output.JSON, output.NDJSON, output.CSV, output.YAML}, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfiles completes the profiles listed in the settings.
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	settings, err := config.Load()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var comps []string
+	for name, path := range settings.Profiles {
+		comps = appendCompletion(comps, toComplete, name, path)
+	}
+	sort.Strings(comps)
+	return comps, cobra.ShellCompDirectiveNoFileComp
+}
+
+// appendCompletion appends value to comps, with a description unless it is
+// empty, if value starts with toComplete.
+func appendCompletion(comps []string, toComplete string, value string, description string) []string {
+	if !strings.HasPrefix(strings.ToLower(value), strings.ToLower(toComplete)) {
+		return comps
+	}
+	if description != "" {
+		value += "\t" + description
+	}
+	return append(comps, value)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/johnjallday/flow-workspace/internal/config"
+	"github.com/johnjallday/flow-workspace/internal/startup"
+	"github.com/spf13/cobra"
+)
+
+var (
+	initUsername string
+	initRoot     string
+)
+
+// initCmd sets up the database without prompting. The username defaults to
+// the one in settings.toml and the database to the one the other commands
+// would use.
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the database and root directory without prompting",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username := initUsername
+		if !cmd.Flags().Changed("username") {
+			username = cfg.Username
+		}
+		dbPath, err := startup.ResolveDBPath(cfg, dbFlag, profile)
+		if err != nil {
+			return err
+		}
+		rootDir, err := config.ExpandHome(initRoot)
+		if err != nil {
+			return err
+		}
+		return startup.Init(startup.InitOptions{Username: username, DBPath: dbPath, RootDir: rootDir})
+	},
+}
+
+func init() {
+	initCmd.Flags().StringVar(&initUsername, "username", "", "username stored in the new database (default: the one in settings.toml)")
+	initCmd.Flags().StringVar(&initRoot, "root", "", "directory to set up as the root of your workspaces")
+}
@@ -1,84 +1,204 @@
 package main
 
 import (
-	"flag"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/johnjallday/flow-workspace/internal/config"
+	"github.com/johnjallday/flow-workspace/internal/output"
 	"github.com/johnjallday/flow-workspace/internal/repl"
-	"github.com/johnjallday/flow-workspace/internal/root"
 	"github.com/johnjallday/flow-workspace/internal/startup"
+	"github.com/johnjallday/flow-workspace/internal/todo"
 	"github.com/johnjallday/flow-workspace/internal/workspace"
+	"github.com/spf13/cobra"
+)
 
-	"github.com/johnjallday/flow-workspace/internal/todo"
+// Settings and global flags shared by every command. cfg is loaded and its
+// database opened by setup before any command runs.
+var (
+	cfg     *config.Config
+	dbFlag  string
+	profile string
+	dryRun  bool
+	format  string
 )
 
+// fwCmd starts the scope-detecting REPL when run without a command.
+var fwCmd = &cobra.Command{
+	Use:   "fw",
+	Short: "Manage workspaces, projects and their todos",
+	Long: `fw manages a root of workspaces, the projects in them and their todos.
+
+Run without a command to open the REPL for the current directory, or use the
+commands below to do the same from scripts, aliases and editors.`,
+	Args:              cobra.NoArgs,
+	SilenceUsage:      true,
+	PersistentPreRunE: setup,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if dryRun {
+			return nil
+		}
+		fmt.Printf("Welcome %s!\n", cfg.Username)
+		fmt.Println("Database path:", cfg.DBPath)
+		repl.StartREPL(cfg)
+		return nil
+	},
+}
+
+func init() {
+	fwCmd.PersistentFlags().StringVar(&dbFlag, "db", "", "path of the database file (overrides $"+startup.DBEnv+")")
+	fwCmd.PersistentFlags().StringVar(&profile, "profile", "", "name of the database profile to use (overrides $"+startup.ProfileEnv+")")
+	fwCmd.PersistentFlags().StringVarP(&format, "format", "o", output.Table, "output format of listings: "+output.FormatHelp)
+	fwCmd.Flags().BoolVar(&dryRun, "migrate-dry-run", false, "print pending database migrations without applying them")
+	fwCmd.RegisterFlagCompletionFunc("format", completeFormats)
+	fwCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+
+	fwCmd.AddCommand(initCmd, todoCmd, projectCmd, workspaceCmd, rootCmd, archiveCmd, syncCmd)
+}
+
 func main() {
-	dbPath := startup.StartDB() // call startDB() from the startup package
-	fmt.Println("Database path:", dbPath)
+	if err := fwCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
 
-	flag.Parse()
-	args := flag.Args()
+// setup loads the settings and opens the database, creating or migrating it
+// as needed, before any command runs. The init command sets the database up
+// itself and only gets the settings. Cobra's help and completion commands need
+// neither, so completion scripts can be generated before fw is set up; the
+// completion functions load the settings themselves.
+func setup(cmd *cobra.Command, args []string) error {
+	if isCobraCommand(cmd) {
+		return nil
+	}
+	if err := output.CheckFormat(format); err != nil {
+		return err
+	}
+	var err error
+	if cfg, err = config.Load(); err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	todo.WeekStart = cfg.WeekStartDay()
+	if cmd == initCmd {
+		return nil
+	}
+	if cfg.DBPath, err = startup.ResolveDBPath(cfg, dbFlag, profile); err != nil {
+		return fmt.Errorf("failed to locate database: %w", err)
+	}
+	if err := startup.StartDB(cfg, dryRun); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	return nil
+}
 
-	// If a command is provided, handle it.
+// isCobraCommand reports whether cmd is one of the help and completion
+// commands cobra adds, e.g. "completion bash" or the hidden "__complete".
+func isCobraCommand(cmd *cobra.Command) bool {
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		switch c.Name() {
+		case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return true
+		}
+	}
+	return false
+}
+
+// dirArg returns the directory named by the optional first argument, or the
+// current directory, as an absolute path.
+func dirArg(args []string) (string, error) {
+	dir := "."
 	if len(args) > 0 {
-		switch args[0] {
-		case "todo":
-			var dir string
-			if len(args) >= 2 {
-				dir = args[1]
-			} else {
-				var err error
-				dir, err = os.Getwd()
-				if err != nil {
-					log.Fatalf("Failed to get current directory: %v", err)
-				}
-			}
+		dir = args[0]
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("directory '%s' does not exist: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("'%s' is not a directory", dir)
+	}
+	return dir, nil
+}
 
-			// Check if the directory exists.
-			info, err := os.Stat(dir)
-			if err != nil {
-				log.Fatalf("Directory '%s' does not exist: %v", dir, err)
-			}
-			if !info.IsDir() {
-				log.Fatalf("Provided path '%s' is not a directory.", dir)
+// projectDirArg is dirArg for a project, which may also be given by its name
+// or alias in the projects.toml of the current workspace.
+func projectDirArg(args []string) (string, error) {
+	if len(args) > 0 && !isDir(args[0]) {
+		if ws := currentWorkspace(); ws != "" {
+			if dir, err := workspace.ProjectDir(ws, args[0]); err == nil {
+				return dir, nil
 			}
+		}
+	}
+	return dirArg(args)
+}
 
-			// Clean up the path.
-			dir = filepath.Clean(dir)
+// workspaceDirArg is dirArg for a workspace, which may also be given by its
+// name in the current root.
+func workspaceDirArg(args []string) (string, error) {
+	if len(args) > 0 && !isDir(args[0]) {
+		if rootDir := currentRoot(); rootDir != "" && isDir(filepath.Join(rootDir, args[0])) {
+			return filepath.Join(rootDir, args[0]), nil
+		}
+	}
+	return dirArg(args)
+}
 
-			// Determine scope by checking for known marker files/folders.
-			configPath := filepath.Join(dir, ".config")
-			if info, err := os.Stat(configPath); err == nil && info.IsDir() {
-				root.ListAllTodos(dir)
-				return
-			}
+// isDir reports whether path is an existing directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
 
-			projectsTomlPath := filepath.Join(dir, "projects.toml")
-			if _, err := os.Stat(projectsTomlPath); err == nil {
-				// Updated to call ListAllTodos instead of StartTodoREPL
-				workspace.ListAllTodos(dir)
-				return
-			}
+// currentWorkspace returns the workspace the current directory is in: the
+// directory itself or the parent of a project. It returns "" outside any workspace.
+func currentWorkspace() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	switch repl.DetectScope(cwd) {
+	case repl.ScopeWorkspace:
+		return cwd
+	case repl.ScopeProject:
+		if parent := filepath.Dir(cwd); repl.DetectScope(parent) == repl.ScopeWorkspace {
+			return parent
+		}
+	}
+	return ""
+}
 
-			projectInfoPath := filepath.Join(dir, "project_info.toml")
-			if _, err := os.Stat(projectInfoPath); err == nil {
-				todoFilePath := filepath.Join(dir, "todo.md")
-				todo.StartTodoREPL(dbPath, todoFilePath)
-				return
+// currentRoot returns the root the current directory is in, or else the
+// root_dir of the settings.
+func currentRoot() string {
+	if cwd, err := os.Getwd(); err == nil {
+		for dir, i := cwd, 0; i < 3; dir, i = filepath.Dir(dir), i+1 {
+			if repl.DetectScope(dir) == repl.ScopeRoot {
+				return dir
 			}
-
-			// Fallback: no known scope marker found.
-			fmt.Println("No known scope markers found in directory.")
-
-		default:
-			fmt.Println("Unknown command. Available commands: todo")
 		}
-		return
 	}
+	return cfg.RootDir
+}
+
+// printListing prints a listing in the format given with --format, calling
+// table to print it for the table format.
+func printListing(table func(), listing *output.Listing) error {
+	if format == output.Table {
+		table()
+		return nil
+	}
+	return output.Write(os.Stdout, format, listing)
+}
 
-	// No command provided: start the general scope-detecting REPL.
-	repl.StartREPL(dbPath)
+// hasMarker reports whether dir contains the file or directory name, which
+// marks it as a root, workspace or project.
+func hasMarker(dir string, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/johnjallday/flow-workspace/internal/project"
+	"github.com/johnjallday/flow-workspace/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	projectName  string
+	projectAlias string
+	projectType  string
+	projectTags  string
+	projectNotes string
+)
+
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Import, show and edit projects",
+	Long: `Import, show and edit projects. Every subcommand works on the project
+directory given as argument, or the current directory. Projects of the current
+workspace can also be given by their name or alias.`,
+}
+
+var projectImportCmd = &cobra.Command{
+	Use:               "import [project-dir]",
+	Short:             "Create the project_info.toml of a directory and register the project",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeDirs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := dirArg(args)
+		if err != nil {
+			return err
+		}
+		return project.ImportProject(cfg.DBPath, dir)
+	},
+}
+
+var projectInfoCmd = &cobra.Command{
+	Use:               "info [project]",
+	Short:             "Show the project info",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := projectDirArg(args)
+		if err != nil {
+			return err
+		}
+		proj, err := project.LoadProjectInfo(filepath.Join(dir, "project_info.toml"))
+		if err != nil {
+			return err
+		}
+		listing := workspace.ProjectListing(&workspace.Projects{Projects: []project.Project{*proj}})
+		return printListing(func() { project.PrintProjectInfo(proj) }, listing)
+	},
+}
+
+var projectEditCmd = &cobra.Command{
+	Use:   "edit [project]",
+	Short: "Change the project info",
+	Long: `Change the project info. Fields without a flag are left as they are; tags
+and notes are comma-separated and replace the current ones.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := projectDirArg(args)
+		if err != nil {
+			return err
+		}
+
+		var edit project.ProjectEdit
+		flags := cmd.Flags()
+		if flags.Changed("name") {
+			edit.Name = &projectName
+		}
+		if flags.Changed("alias") {
+			edit.Alias = &projectAlias
+		}
+		if flags.Changed("type") {
+			edit.ProjectType = &projectType
+		}
+		if flags.Changed("tags") {
+			tags := project.ParseList(projectTags)
+			edit.Tags = &tags
+		}
+		if flags.Changed("notes") {
+			notes := project.ParseList(projectNotes)
+			edit.Notes = &notes
+		}
+		if edit == (project.ProjectEdit{}) {
+			return fmt.Errorf("nothing to change; see 'fw project edit --help'")
+		}
+
+		if err := project.EditProjectInfo(cfg.DBPath, dir, edit); err != nil {
+			return err
+		}
+		fmt.Println("Project info updated successfully.")
+		return nil
+	},
+}
+
+func init() {
+	projectEditCmd.Flags().StringVar(&projectName, "name", "", "project name")
+	projectEditCmd.Flags().StringVar(&projectAlias, "alias", "", "project alias")
+	projectEditCmd.Flags().StringVar(&projectType, "type", "", "project type")
+	projectEditCmd.Flags().StringVar(&projectTags, "tags", "", "comma-separated tags")
+	projectEditCmd.Flags().StringVar(&projectNotes, "notes", "", "comma-separated notes")
+
+	projectCmd.AddCommand(projectImportCmd, projectInfoCmd, projectEditCmd)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+
+	"github.com/johnjallday/flow-workspace/internal/output"
+	"github.com/johnjallday/flow-workspace/internal/root"
+	"github.com/johnjallday/flow-workspace/internal/todo"
+	"github.com/spf13/cobra"
+)
+
+var rootFilter string
+
+var rootCmd = &cobra.Command{
+	Use:   "root",
+	Short: "Work across every workspace of a root",
+}
+
+var rootTodosCmd = &cobra.Command{
+	Use:   "todos [root-dir]",
+	Short: "List the todos of every workspace",
+	Long: `List the todos of every workspace under the root directory given as argument,
+the root_dir of the settings, or the current directory.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeDirs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := rootDirArg(args)
+		if err != nil {
+			return err
+		}
+		if format == output.Table {
+			root.ListAllTodos(cfg, dir, rootFilter)
+			return nil
+		}
+		todos := root.CollectTodos(cfg, dir)
+		if rootFilter != "" {
+			todos = todo.FilterTodos(todos, rootFilter)
+		}
+		todo.SortByPriority(todos)
+		return output.Write(os.Stdout, format, todo.TodoListing(todos))
+	},
+}
+
+var rootWorkspacesCmd = &cobra.Command{
+	Use:               "workspaces [root-dir]",
+	Short:             "List the workspaces of a root",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeDirs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := rootDirArg(args)
+		if err != nil {
+			return err
+		}
+		if format == output.Table {
+			root.ListWorkspaces(cfg, dir)
+			return nil
+		}
+		listing, err := root.WorkspaceListing(cfg, dir)
+		if err != nil {
+			return err
+		}
+		return output.Write(os.Stdout, format, listing)
+	},
+}
+
+func init() {
+	rootTodosCmd.Flags().StringVarP(&rootFilter, "filter", "f", "", "only list the tasks matching the filter, e.g. \"#tag due:today\"")
+
+	rootCmd.AddCommand(rootTodosCmd, rootWorkspacesCmd)
+}
+
+// rootDirArg returns the root directory given as argument, the root_dir of
+// the settings, or the current directory.
+func rootDirArg(args []string) (string, error) {
+	if len(args) == 0 && cfg.RootDir != "" {
+		args = []string{cfg.RootDir}
+	}
+	return dirArg(args)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+
+	dbtodo "github.com/johnjallday/flow-workspace/internal/db/todo"
+	"github.com/johnjallday/flow-workspace/internal/todo"
+	"github.com/spf13/cobra"
+)
+
+// syncCmd syncs the todo.md of a project directory with the database.
+var syncCmd = &cobra.Command{
+	Use:               "sync [project]",
+	Short:             "Sync a project's todo.md with the database",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := projectDirArg(args)
+		if err != nil {
+			return err
+		}
+		conn, err := dbtodo.InitDB(cfg.DBPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		result, err := todo.SyncProject(conn, filepath.Join(dir, "todo.md"), nil)
+		if err != nil {
+			return err
+		}
+		todo.PrintSyncResult(result)
+		return nil
+	},
+}
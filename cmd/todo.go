@@ -0,0 +1,299 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	dbtodo "github.com/johnjallday/flow-workspace/internal/db/todo"
+	"github.com/johnjallday/flow-workspace/internal/output"
+	"github.com/johnjallday/flow-workspace/internal/project"
+	"github.com/johnjallday/flow-workspace/internal/root"
+	"github.com/johnjallday/flow-workspace/internal/todo"
+	"github.com/johnjallday/flow-workspace/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	todoDir      string
+	todoDue      string
+	todoPriority string
+	todoFilter   string
+	todoDesc     string
+	todoStatus   string
+)
+
+// todoCmd lists the todos of a root or workspace, or opens the todo REPL of
+// a project, depending on the markers found in the directory.
+var todoCmd = &cobra.Command{
+	Use:   "todo [dir]",
+	Short: "Show the todos of a root, workspace or project",
+	Long: `Without a subcommand, todo lists every todo of a root or workspace directory,
+or opens the todo REPL of a project directory.
+
+The subcommands work on the project given with --dir, or the current directory.
+Tasks are given by their number in "todo list" or by their ID.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeDirs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := dirArg(args)
+		if err != nil {
+			return err
+		}
+		// Listings are for scripts; only the table format opens the REPL.
+		if format != output.Table {
+			todos, err := scopeTodos(dir)
+			if err != nil {
+				return err
+			}
+			return output.Write(os.Stdout, format, todo.TodoListing(todos))
+		}
+		switch {
+		case hasMarker(dir, ".config"):
+			root.ListAllTodos(cfg, dir, "")
+		case hasMarker(dir, "projects.toml"):
+			workspace.ListAllTodos(cfg, dir, "")
+		case hasMarker(dir, "project_info.toml"):
+			todo.StartTodoREPL(cfg, filepath.Join(dir, "todo.md"))
+		default:
+			return fmt.Errorf("no known scope markers found in '%s'", dir)
+		}
+		return nil
+	},
+}
+
+var todoAddCmd = &cobra.Command{
+	Use:   "add <description>...",
+	Short: "Add a task and print its ID",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := projectDir()
+		if err != nil {
+			return err
+		}
+		conn, err := dbtodo.InitDB(cfg.DBPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		id, err := project.AddTodoToProject(conn, dir, strings.Join(args, " "), todoDue, todoPriority)
+		if err != nil {
+			return err
+		}
+		fmt.Println(id)
+		return nil
+	},
+}
+
+var todoListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the tasks of a project",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dbtodo.InitDB(cfg.DBPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		service, err := projectService(conn)
+		if err != nil {
+			return err
+		}
+		todos, err := service.ListTodos()
+		if err != nil {
+			return err
+		}
+		if todoFilter != "" {
+			todos = todo.FilterTodos(todos, todoFilter)
+		}
+		return printListing(func() { todo.PrintTodos(todos) }, todo.TodoListing(todos))
+	},
+}
+
+var todoWeeklyCmd = &cobra.Command{
+	Use:   "weekly",
+	Short: "Review the tasks of a project completed this week and still open",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dbtodo.InitDB(cfg.DBPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		service, err := projectService(conn)
+		if err != nil {
+			return err
+		}
+		todos, err := service.ListTodos()
+		if err != nil {
+			return err
+		}
+		review := todo.NewWeeklyReview(todos, cfg.WeekStartDay(), time.Now())
+		return printListing(func() { todo.ReviewWeekly(todos, cfg.WeekStartDay()) }, review.Listing())
+	},
+}
+
+var todoDoneCmd = &cobra.Command{
+	Use:               "done <task>...",
+	Short:             "Mark tasks as completed",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeTasks(true),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dbtodo.InitDB(cfg.DBPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		service, ids, err := resolveTasks(conn, args)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := service.CompleteTodoByID(id); err != nil {
+				return fmt.Errorf("failed to complete task %s: %w", id, err)
+			}
+			fmt.Printf("Task %s marked as completed.\n", id)
+		}
+		return nil
+	},
+}
+
+var todoEditCmd = &cobra.Command{
+	Use:   "edit <task>",
+	Short: "Change the description, due date, status or priority of a task",
+	Long: `Change the description, due date, status or priority of a task. Fields
+without a flag are left as they are; a priority of "0" or "none" clears it.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTasks(false),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dbtodo.InitDB(cfg.DBPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		service, ids, err := resolveTasks(conn, args)
+		if err != nil {
+			return err
+		}
+		if err := service.EditTodoByID(ids[0], todoDesc, todoDue, todoStatus, todoPriority); err != nil {
+			return err
+		}
+		fmt.Printf("Task %s updated.\n", ids[0])
+		return nil
+	},
+}
+
+var todoRmCmd = &cobra.Command{
+	Use:               "rm <task>...",
+	Aliases:           []string{"delete"},
+	Short:             "Delete tasks",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeTasks(false),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dbtodo.InitDB(cfg.DBPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		service, ids, err := resolveTasks(conn, args)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := service.DeleteTodoByID(id); err != nil {
+				return fmt.Errorf("failed to delete task %s: %w", id, err)
+			}
+			fmt.Printf("Task %s deleted.\n", id)
+		}
+		return nil
+	},
+}
+
+func init() {
+	todoCmd.PersistentFlags().StringVarP(&todoDir, "dir", "C", "", "project directory, name or alias (default: the current directory)")
+	todoCmd.RegisterFlagCompletionFunc("dir", completeProjects)
+
+	todoAddCmd.Flags().StringVar(&todoDue, "due", "", "due date ("+todo.DateInputHelp+")")
+	todoAddCmd.Flags().StringVarP(&todoPriority, "priority", "p", "", "priority (1-4 or A-D)")
+
+	todoListCmd.Flags().StringVarP(&todoFilter, "filter", "f", "", "only list the tasks matching the filter, e.g. \"#tag due:today\"")
+
+	todoEditCmd.Flags().StringVarP(&todoDesc, "description", "d", "", "new description")
+	todoEditCmd.Flags().StringVar(&todoDue, "due", "", "new due date ("+todo.DateInputHelp+")")
+	todoEditCmd.Flags().StringVar(&todoStatus, "status", "", "new status (ongoing or complete)")
+	todoEditCmd.Flags().StringVarP(&todoPriority, "priority", "p", "", "new priority (1-4 or A-D, 0 or none to clear)")
+
+	todoCmd.AddCommand(todoAddCmd, todoListCmd, todoDoneCmd, todoEditCmd, todoRmCmd, todoWeeklyCmd)
+}
+
+// scopeTodos returns the todos of the root, workspace or project in dir,
+// sorted by priority.
+func scopeTodos(dir string) ([]todo.Todo, error) {
+	var todos []todo.Todo
+	var err error
+	switch {
+	case hasMarker(dir, ".config"):
+		todos = root.CollectTodos(cfg, dir)
+	case hasMarker(dir, "projects.toml"):
+		todos, err = workspace.CollectTodos(cfg, dir)
+	case hasMarker(dir, "project_info.toml"):
+		todos, err = todo.LoadProjectTodos(cfg.DBPath, dir)
+	default:
+		err = fmt.Errorf("no known scope markers found in '%s'", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	todo.SortByPriority(todos)
+	return todos, nil
+}
+
+// projectDir returns the project given with --dir, or the current directory.
+func projectDir() (string, error) {
+	var args []string
+	if todoDir != "" {
+		args = []string{todoDir}
+	}
+	dir, err := projectDirArg(args)
+	if err != nil {
+		return "", err
+	}
+	if !hasMarker(dir, "project_info.toml") {
+		return "", fmt.Errorf("'%s' is not a project (no project_info.toml)", dir)
+	}
+	return dir, nil
+}
+
+// projectService returns the TodoService of the project given with --dir,
+// using conn if the project keeps its tasks in the database.
+func projectService(conn *sql.DB) (todo.TodoService, error) {
+	dir, err := projectDir()
+	if err != nil {
+		return nil, err
+	}
+	return todo.NewTodoService(conn, filepath.Join(dir, "todo.md"))
+}
+
+// resolveTasks returns the TodoService of the project given with --dir and
+// the IDs of the tasks given as arguments. Every task is resolved against the
+// same listing before any of them is changed, so the numbers stay valid.
+func resolveTasks(conn *sql.DB, args []string) (todo.TodoService, []string, error) {
+	service, err := projectService(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	todos, err := service.ListTodos()
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]string, len(args))
+	for i, arg := range args {
+		if ids[i], err = todo.ResolveTodoID(todos, arg); err != nil {
+			return nil, nil, err
+		}
+	}
+	return service, ids, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/johnjallday/flow-workspace/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var workspaceCmd = &cobra.Command{
+	Use:   "workspace",
+	Short: "List and update the projects of a workspace",
+	Long: `List and update the projects of a workspace. Every subcommand works on the
+workspace directory given as argument, or the current directory. Workspaces of
+the current root can also be given by their name.`,
+}
+
+var workspaceListCmd = &cobra.Command{
+	Use:               "list [workspace]",
+	Short:             "List the projects in projects.toml",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeWorkspaces,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := workspaceDirArg(args)
+		if err != nil {
+			return err
+		}
+		projs, err := workspace.LoadProjectsToml(dir)
+		if err != nil {
+			return err
+		}
+		return printListing(func() { workspace.ListProjects(projs) }, workspace.ProjectListing(projs))
+	},
+}
+
+var workspaceUpdateCmd = &cobra.Command{
+	Use:               "update [workspace]",
+	Short:             "Rescan the projects of a workspace into projects.toml and the database",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeWorkspaces,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := workspaceDirArg(args)
+		if err != nil {
+			return err
+		}
+		projs, err := workspace.UpdateProjects(cfg, dir)
+		if err != nil {
+			return err
+		}
+		return printListing(func() { workspace.ListProjects(projs) }, workspace.ProjectListing(projs))
+	},
+}
+
+func init() {
+	workspaceCmd.AddCommand(workspaceListCmd, workspaceUpdateCmd)
+}
@@ -4,19 +4,20 @@ go 1.23.0
 
 require (
 	github.com/BurntSushi/toml v1.4.0
+	github.com/chzyer/readline v1.5.1
+	github.com/fatih/color v1.18.0
+	github.com/mattn/go-sqlite3 v1.14.24
+	github.com/olekukonko/tablewriter v0.0.5
 	github.com/sashabaranov/go-openai v1.36.1
 	github.com/spf13/cobra v1.9.1
+	golang.org/x/term v0.28.0
 )
 
 require (
-	github.com/fatih/color v1.18.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-runewidth v0.0.9 // indirect
-	github.com/mattn/go-sqlite3 v1.14.24 // indirect
-	github.com/olekukonko/tablewriter v0.0.5 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
 	golang.org/x/sys v0.29.0 // indirect
-	golang.org/x/term v0.28.0 // indirect
 )
@@ -1,14 +1,14 @@
 package agent
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
-	"strings"
+
+	"github.com/johnjallday/flow-workspace/internal/shell"
 )
 
 // Agent represents the configuration for the CLI agent.
@@ -40,22 +40,6 @@ func getCommands(agentPath string) ([]Command, error) {
 	return commands, nil
 }
 
-// printCommands prints the available commands in a style similar to your project REPL.
-func printCommands(commands []Command) {
-	fmt.Println("Available commands (Agent REPL):")
-
-	// Sort the commands alphabetically.
-	sortedCommands := make([]Command, len(commands))
-	copy(sortedCommands, commands)
-	sort.Slice(sortedCommands, func(i, j int) bool {
-		return sortedCommands[i].Name < sortedCommands[j].Name
-	})
-
-	for _, cmd := range sortedCommands {
-		fmt.Printf("  %-10s - %s\n", cmd.Name, cmd.Description)
-	}
-}
-
 // LaunchAgent executes the agent binary with the specified command.
 // It pipes the output to stdout/stderr.
 func LaunchAgent(agentPath string, commandName string) {
@@ -83,39 +67,33 @@ func StartAgentREPL(agentPath string) {
 		return
 	}
 
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("Agent REPL started for binary: %s\n", absAgentPath)
-	printCommands(commandsList)
-
-	for {
-		fmt.Printf("\n[agent:%s] >> ", filepath.Base(absAgentPath))
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Println("Error reading input:", err)
-			return
-		}
-		line = strings.TrimSpace(line)
+	// Sort the commands alphabetically.
+	sort.Slice(commandsList, func(i, j int) bool {
+		return commandsList[i].Name < commandsList[j].Name
+	})
 
-		switch strings.ToLower(line) {
-		case "exit":
-			fmt.Println("Exiting Agent REPL. Goodbye!")
-			return
-		case "help", "commands":
-			printCommands(commandsList)
-		default:
-			// Check if the entered command exists in the list.
-			var found bool
-			for _, cmd := range commandsList {
-				if strings.EqualFold(cmd.Name, line) {
-					found = true
-					fmt.Printf("Executing command: %s\n", cmd.Name)
-					LaunchAgent(absAgentPath, cmd.Name)
-					break
-				}
-			}
-			if !found {
-				fmt.Println("Unknown command. Type 'commands' for available commands.")
-			}
-		}
+	fmt.Printf("Agent REPL started for binary: %s\n", absAgentPath)
+	sh := shell.New("Agent REPL", "agent", fmt.Sprintf("\n[agent:%s] >> ", filepath.Base(absAgentPath)))
+	for _, cmd := range commandsList {
+		name := cmd.Name
+		sh.Register(&shell.Command{
+			Name: name,
+			Help: cmd.Description,
+			Run: func(c *shell.Context) error {
+				fmt.Printf("Executing command: %s\n", name)
+				LaunchAgent(absAgentPath, name)
+				return nil
+			},
+		})
 	}
+	sh.Register(&shell.Command{
+		Name: "commands",
+		Help: "List the commands of the agent",
+		Run: func(c *shell.Context) error {
+			c.Shell.PrintHelp()
+			return nil
+		},
+	})
+	sh.PrintHelp()
+	sh.Run()
 }
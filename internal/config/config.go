@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// AppName names the per-user configuration and data directories.
+const AppName = "flow-workspace"
+
+// Modes of the archive setting: when finished tasks leave the live list.
+const (
+	ArchiveAuto   = "auto"   // whenever a project or todo REPL is opened
+	ArchiveManual = "manual" // only with the archive-finished command
+	ArchiveNever  = "never"  // never
+)
+
+// ArchivePolicy decides which finished tasks are archived and when. A task is
+// archived AfterDays days after it was completed, unless it is one of the
+// KeepLast most recently completed tasks of its project.
+type ArchivePolicy struct {
+	Mode      string
+	AfterDays int
+	KeepLast  int
+	Quiet     bool // don't report what was archived when a REPL is opened
+}
+
+// Cutoff returns the moment before which finished tasks are old enough to archive.
+func (p ArchivePolicy) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -p.AfterDays)
+}
+
+// Check returns an error if the policy is invalid.
+func (p ArchivePolicy) Check() error {
+	switch p.Mode {
+	case ArchiveAuto, ArchiveManual, ArchiveNever:
+	default:
+		return fmt.Errorf("invalid archive mode '%s' (use %s, %s or %s)", p.Mode, ArchiveAuto, ArchiveManual, ArchiveNever)
+	}
+	if p.AfterDays < 0 {
+		return fmt.Errorf("archive_after_days must not be negative")
+	}
+	if p.KeepLast < 0 {
+		return fmt.Errorf("archive_keep_last must not be negative")
+	}
+	return nil
+}
+
+// Config holds the settings read from settings.toml. Every REPL receives the
+// loaded Config; DBPath is resolved at startup and is not read from the file.
+//
+//	username = "jj"
+//	root_dir = "~/Workspace"
+//	skip_dirs = [".config", ".git"]
+//	archive = "auto"
+//	archive_after_days = 7
+//	archive_keep_last = 0
+//	archive_quiet = false
+//	week_start = "saturday"
+//
+//	[agents]
+//	coder = "~/bin/gorani-coder"
+type Config struct {
+	Username string `toml:"username"` // used instead of prompting when creating a database
+	AppDir   string `toml:"app_dir"`  // directory searched for fw_*.sqlite databases of older versions
+	RootDir  string `toml:"root_dir"` // root opened when the REPL is started outside any scope
+
+	DB       string            `toml:"db"`       // database used without a profile
+	Profile  string            `toml:"profile"`  // profile used when none is given
+	Profiles map[string]string `toml:"profiles"` // database of each named profile
+
+	SkipDirs  []string          `toml:"skip_dirs"`  // directories never treated as workspaces or searched
+	Agents    map[string]string `toml:"agents"`     // paths of the agent binaries by name
+	WeekStart string            `toml:"week_start"` // first day of the weekly review period
+
+	// Default archive policy, which projects can override in project_info.toml.
+	Archive          string `toml:"archive"`
+	ArchiveAfterDays int    `toml:"archive_after_days"`
+	ArchiveKeepLast  int    `toml:"archive_keep_last"`
+	ArchiveQuiet     bool   `toml:"archive_quiet"`
+
+	DBPath string `toml:"-"`
+}
+
+// Default returns the settings used for everything settings.toml leaves out.
+func Default() *Config {
+	return &Config{
+		Profiles:         make(map[string]string),
+		SkipDirs:         []string{".config", ".spacedrive", ".DS_Store", ".TagStudio", ".git"},
+		Agents:           make(map[string]string),
+		Archive:          ArchiveAuto,
+		ArchiveAfterDays: 7,
+		WeekStart:        "saturday",
+	}
+}
+
+// Paths returns the settings.toml files read by Load, in the order they are
+// applied: the one next to the binary, then the user's own in the user
+// configuration directory.
+func Paths() []string {
+	var paths []string
+	if exePath, err := os.Executable(); err == nil {
+		paths = append(paths, filepath.Join(filepath.Dir(exePath), "settings.toml"))
+	}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(configDir, AppName, "settings.toml"))
+	}
+	return paths
+}
+
+// Load reads every existing file in Paths over the defaults, later files
+// overriding the settings of earlier ones, and checks the result.
+func Load() (*Config, error) {
+	return LoadFiles(Paths()...)
+}
+
+// LoadFiles reads the given settings files over the defaults, skipping the
+// ones that don't exist.
+func LoadFiles(paths ...string) (*Config, error) {
+	cfg := Default()
+	for _, path := range paths {
+		if _, err := toml.DecodeFile(path, cfg); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading '%s': %w", path, err)
+		}
+	}
+
+	var err error
+	for _, p := range []*string{&cfg.AppDir, &cfg.RootDir, &cfg.DB} {
+		if *p, err = ExpandHome(*p); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range []map[string]string{cfg.Profiles, cfg.Agents} {
+		for name, path := range m {
+			if m[name], err = ExpandHome(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := cfg.ArchivePolicy().Check(); err != nil {
+		return nil, err
+	}
+	if _, err := parseWeekday(cfg.WeekStart); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Skipped reports whether the directory or file named name is in SkipDirs.
+func (c *Config) Skipped(name string) bool {
+	for _, skip := range c.SkipDirs {
+		if name == skip {
+			return true
+		}
+	}
+	return false
+}
+
+// AgentPath returns the path of the agent binary called name, or "" if none is configured.
+func (c *Config) AgentPath(name string) string {
+	return c.Agents[name]
+}
+
+// ArchivePolicy returns the default archive policy of the settings.
+func (c *Config) ArchivePolicy() ArchivePolicy {
+	return ArchivePolicy{
+		Mode:      strings.ToLower(strings.TrimSpace(c.Archive)),
+		AfterDays: c.ArchiveAfterDays,
+		KeepLast:  c.ArchiveKeepLast,
+		Quiet:     c.ArchiveQuiet,
+	}
+}
+
+// WeekStartDay returns the first day of the weekly review period.
+func (c *Config) WeekStartDay() time.Weekday {
+	day, err := parseWeekday(c.WeekStart)
+	if err != nil {
+		return time.Saturday
+	}
+	return day
+}
+
+// parseWeekday parses a weekday name such as "monday" or "mon".
+func parseWeekday(name string) (time.Weekday, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full[:3] {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid week_start '%s'", name)
+}
+
+// ExpandHome replaces a leading "~" in path with the user's home directory.
+func ExpandHome(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return filepath.Clean(path), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
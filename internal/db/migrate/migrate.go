@@ -0,0 +1,263 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration is a single, numbered change to the database schema. Up must be
+// idempotent so that a database created or partially updated by an older
+// version without a schema_version table can be brought up to date safely.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in the order it is applied. New
+// migrations are appended with the next version number; existing ones must
+// never be changed or reordered.
+var migrations = []Migration{
+	{1, "create todos and config tables", func(tx *sql.Tx) error {
+		return execAll(tx, `
+		CREATE TABLE IF NOT EXISTS todos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			description TEXT NOT NULL,
+			completed_date DATETIME,
+			created_date DATETIME NOT NULL,
+			due_date DATETIME,
+			project_name TEXT,
+			workspace_name TEXT
+		);`, `
+		CREATE TABLE IF NOT EXISTS config (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT NOT NULL
+		);`)
+	}},
+	{2, "add tags and notes to todos", func(tx *sql.Tx) error {
+		return addColumns(tx, "todos", [][2]string{
+			{"tags", "TEXT"},
+			{"notes", "TEXT"},
+		})
+	}},
+	{3, "store live tasks in todos", func(tx *sql.Tx) error {
+		return addColumns(tx, "todos", [][2]string{
+			{"task_id", "TEXT"},
+			{"status", "TEXT NOT NULL DEFAULT 'archived'"},
+			{"ongoing", "INTEGER NOT NULL DEFAULT 0"},
+			{"priority", "INTEGER NOT NULL DEFAULT 0"},
+			{"due", "TEXT"},
+			{"recur", "TEXT"},
+			{"series", "TEXT"},
+			{"parent_id", "TEXT"},
+			{"blocked_by", "TEXT"},
+			{"position", "INTEGER NOT NULL DEFAULT 0"},
+		})
+	}},
+	{4, "create sync_state table", func(tx *sql.Tx) error {
+		return execAll(tx, `
+		CREATE TABLE IF NOT EXISTS sync_state (
+			workspace_name TEXT NOT NULL,
+			project_name TEXT NOT NULL,
+			task_id TEXT NOT NULL,
+			hash TEXT NOT NULL,
+			synced_at DATETIME NOT NULL,
+			PRIMARY KEY (workspace_name, project_name, task_id)
+		);`)
+	}},
+	{5, "create workspaces and projects tables", func(tx *sql.Tx) error {
+		if err := execAll(tx, `
+		CREATE TABLE IF NOT EXISTS workspaces (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			path TEXT NOT NULL,
+			updated_at DATETIME NOT NULL
+		);`, `
+		CREATE TABLE IF NOT EXISTS projects (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+			dir TEXT NOT NULL,
+			name TEXT NOT NULL,
+			alias TEXT,
+			project_type TEXT,
+			notes TEXT,
+			path TEXT NOT NULL,
+			git_url TEXT,
+			todo_store TEXT,
+			date_created DATETIME,
+			date_modified DATETIME,
+			updated_at DATETIME NOT NULL,
+			UNIQUE (workspace_id, dir)
+		);`, `
+		CREATE TABLE IF NOT EXISTS project_tags (
+			project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+			tag TEXT NOT NULL COLLATE NOCASE,
+			PRIMARY KEY (project_id, tag)
+		);`,
+			`CREATE INDEX IF NOT EXISTS projects_type ON projects (project_type)`,
+			`CREATE INDEX IF NOT EXISTS projects_modified ON projects (date_modified)`,
+			`CREATE INDEX IF NOT EXISTS project_tags_tag ON project_tags (tag)`,
+		); err != nil {
+			return err
+		}
+		if err := addColumns(tx, "todos", [][2]string{
+			{"project_id", "INTEGER REFERENCES projects(id) ON DELETE SET NULL"},
+		}); err != nil {
+			return err
+		}
+		return execAll(tx, `CREATE INDEX IF NOT EXISTS todos_project ON todos (project_id)`)
+	}},
+}
+
+// Latest returns the schema version the migrations bring a database to.
+func Latest() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// Current returns the schema version of db, or 0 if no migration has been
+// recorded. It only reads db, so it can be used for a dry run.
+func Current(db *sql.DB) (int, error) {
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'").Scan(&tables); err != nil {
+		return 0, fmt.Errorf("error reading schema version: %w", err)
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+	var version int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("error reading schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Pending returns the migrations that have not been applied to db yet, in order.
+func Pending(db *sql.DB) ([]Migration, error) {
+	current, err := Current(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Apply runs the pending migrations of db in order, each in its own
+// transaction, and returns the ones that were applied.
+func Apply(db *sql.DB) ([]Migration, error) {
+	if err := createVersionTable(db); err != nil {
+		return nil, err
+	}
+	pending, err := Pending(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		tx, err := db.Begin()
+		if err != nil {
+			return applied, err
+		}
+		if err := m.Up(tx); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)",
+			m.Version, m.Description, time.Now()); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("error recording migration %d: %w", m.Version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// PrintPending prints the migrations Apply would run on db without running
+// them or otherwise changing db.
+func PrintPending(db *sql.DB) error {
+	current, err := Current(db)
+	if err != nil {
+		return err
+	}
+	pending, err := Pending(db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema version %d, latest is %d.\n", current, Latest())
+	if len(pending) == 0 {
+		fmt.Println("No pending migrations.")
+		return nil
+	}
+	fmt.Println("Pending migrations:")
+	for _, m := range pending {
+		fmt.Printf("  %d: %s\n", m.Version, m.Description)
+	}
+	return nil
+}
+
+// createVersionTable creates the "schema_version" table if it does not already exist.
+func createVersionTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("error creating schema_version table: %w", err)
+	}
+	return nil
+}
+
+// execAll executes the given statements in order.
+func execAll(tx *sql.Tx, statements ...string) error {
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumns adds the given columns to table unless it already has them, so
+// databases whose tables were created with the columns are left as they are.
+func addColumns(tx *sql.Tx, table string, columns [][2]string) error {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range columns {
+		if existing[column[0]] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column[0], column[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
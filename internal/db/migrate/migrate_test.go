@@ -0,0 +1,248 @@
+package migrate
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// openDB returns an empty database in a temporary directory.
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	return openDBFile(t, filepath.Join(t.TempDir(), "flow.sqlite"))
+}
+
+// openDBFile opens the database in the file at path.
+func openDBFile(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", path+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// columns returns the column names of table.
+func columns(t *testing.T, db *sql.DB, table string) map[string]bool {
+	t.Helper()
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestMigrationsAreNumberedInOrder(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d (%s) has version %d", i+1, m.Description, m.Version)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		legacy []string // statements run before Apply, as an older version would have
+	}{
+		{name: "fresh database"},
+		{name: "legacy todos table", legacy: []string{`
+			CREATE TABLE todos (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				description TEXT NOT NULL,
+				completed_date DATETIME,
+				created_date DATETIME NOT NULL,
+				due_date DATETIME,
+				project_name TEXT,
+				workspace_name TEXT,
+				tags TEXT
+			)`,
+			`INSERT INTO todos (description, created_date, tags) VALUES ('old task', '2025-01-01', '["x"]')`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openDB(t)
+			for _, statement := range tt.legacy {
+				if _, err := db.Exec(statement); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			applied, err := Apply(db)
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if len(applied) != len(migrations) {
+				t.Errorf("Apply applied %d migrations, want %d", len(applied), len(migrations))
+			}
+			if current, err := Current(db); err != nil || current != Latest() {
+				t.Errorf("Current = %d, %v; want %d", current, err, Latest())
+			}
+			for _, column := range []string{"tags", "notes", "task_id", "status", "project_id"} {
+				if !columns(t, db, "todos")[column] {
+					t.Errorf("todos has no %s column", column)
+				}
+			}
+
+			// A second run finds nothing to do and leaves the schema as it is.
+			applied, err = Apply(db)
+			if err != nil {
+				t.Fatalf("second Apply: %v", err)
+			}
+			if len(applied) != 0 {
+				t.Errorf("second Apply applied %d migrations, want none", len(applied))
+			}
+			if pending, err := Pending(db); err != nil || len(pending) != 0 {
+				t.Errorf("Pending = %d migrations, %v; want none", len(pending), err)
+			}
+			var recorded int
+			if err := db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&recorded); err != nil {
+				t.Fatal(err)
+			}
+			if recorded != len(migrations) {
+				t.Errorf("schema_version has %d rows, want %d", recorded, len(migrations))
+			}
+
+			if len(tt.legacy) > 0 {
+				var status string
+				if err := db.QueryRow("SELECT status FROM todos WHERE description = 'old task'").Scan(&status); err != nil {
+					t.Fatal(err)
+				}
+				if status != "archived" {
+					t.Errorf("legacy task has status %q, want archived", status)
+				}
+			}
+		})
+	}
+}
+
+func TestPending(t *testing.T) {
+	db := openDB(t)
+	if current, err := Current(db); err != nil || current != 0 {
+		t.Fatalf("Current of an empty database = %d, %v; want 0", current, err)
+	}
+
+	// Record the first migrations as applied, as an older version would have.
+	if err := createVersionTable(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:2] {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Up(tx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, CURRENT_TIMESTAMP)",
+			m.Version, m.Description); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending, err := Pending(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(migrations)-2 {
+		t.Fatalf("Pending returned %d migrations, want %d", len(pending), len(migrations)-2)
+	}
+	for i, m := range pending {
+		if m.Version != i+3 {
+			t.Errorf("pending[%d] has version %d, want %d", i, m.Version, i+3)
+		}
+	}
+
+	applied, err := Apply(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(pending) {
+		t.Fatalf("Apply applied %d migrations, want %d", len(applied), len(pending))
+	}
+	if applied[0].Version != 3 {
+		t.Errorf("Apply started at migration %d, want 3", applied[0].Version)
+	}
+}
+
+func TestPrintPendingLeavesDatabaseUnchanged(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   []string
+		migrate bool
+	}{
+		{name: "empty database"},
+		{name: "legacy database without schema_version", setup: []string{`
+			CREATE TABLE todos (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				description TEXT NOT NULL,
+				completed_date DATETIME,
+				created_date DATETIME NOT NULL,
+				due_date DATETIME,
+				project_name TEXT,
+				workspace_name TEXT
+			)`,
+		}},
+		{name: "up to date database", migrate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A dry run only looks at databases that exist.
+			path := filepath.Join(t.TempDir(), "flow.sqlite")
+			if err := os.WriteFile(path, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			db := openDBFile(t, path)
+			for _, statement := range tt.setup {
+				if _, err := db.Exec(statement); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.migrate {
+				if _, err := Apply(db); err != nil {
+					t.Fatal(err)
+				}
+			}
+			db.Close()
+			before, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db = openDBFile(t, path)
+			if err := PrintPending(db); err != nil {
+				t.Fatalf("PrintPending: %v", err)
+			}
+			db.Close()
+
+			after, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(before, after) {
+				t.Errorf("PrintPending changed the database file")
+			}
+		})
+	}
+}
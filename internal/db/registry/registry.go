@@ -0,0 +1,322 @@
+package registry
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// ProjectQueryHelp describes the terms accepted by ParseProjectQuery.
+const ProjectQueryHelp = "tag:<tag> type:<type> stale:<days>"
+
+// tagSeparator joins the tags of a project in queries; it can't appear in a tag.
+const tagSeparator = "\x1f"
+
+// ProjectRecord is the metadata of a project as stored in the projects table.
+type ProjectRecord struct {
+	Workspace    string // name of the workspace directory
+	Dir          string // name of the project directory, as used by its tasks
+	Name         string
+	Alias        string
+	ProjectType  string
+	Tags         []string
+	Notes        []string
+	Path         string
+	GitURL       string
+	TodoStore    string
+	DateCreated  time.Time
+	DateModified time.Time
+}
+
+// SaveWorkspace records the workspace in workspaceDir and replaces its
+// projects with projects, removing the projects no longer found in it.
+// Tasks of the projects are linked to their rows.
+func SaveWorkspace(db *sql.DB, workspaceDir string, projects []ProjectRecord) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	workspaceID, err := saveWorkspace(tx, workspaceDir)
+	if err != nil {
+		return err
+	}
+	dirs := make([]interface{}, 0, len(projects)+1)
+	dirs = append(dirs, workspaceID)
+	for _, p := range projects {
+		if err := saveProject(tx, workspaceID, p); err != nil {
+			return err
+		}
+		dirs = append(dirs, p.Dir)
+	}
+
+	query := "DELETE FROM projects WHERE workspace_id = ?"
+	if len(projects) > 0 {
+		query += " AND dir NOT IN (?" + strings.Repeat(", ?", len(projects)-1) + ")"
+	}
+	if _, err := tx.Exec(query, dirs...); err != nil {
+		return fmt.Errorf("error removing projects: %w", err)
+	}
+	if err := linkTodos(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveProject records a single project of the workspace in workspaceDir and
+// links its tasks to it.
+func SaveProject(db *sql.DB, workspaceDir string, p ProjectRecord) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	workspaceID, err := saveWorkspace(tx, workspaceDir)
+	if err != nil {
+		return err
+	}
+	if err := saveProject(tx, workspaceID, p); err != nil {
+		return err
+	}
+	if err := linkTodos(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ProjectQuery selects registered projects. Empty fields match every project.
+type ProjectQuery struct {
+	Workspace   string
+	Tag         string
+	ProjectType string
+	StaleBefore time.Time // only projects not modified since
+}
+
+// ParseProjectQuery parses a query such as "tag:go type:coding stale:30",
+// where stale selects the projects not modified in the given number of days.
+func ParseProjectQuery(input string, now time.Time) (ProjectQuery, error) {
+	var q ProjectQuery
+	for _, term := range strings.Fields(input) {
+		key, value, ok := strings.Cut(term, ":")
+		if !ok || value == "" {
+			return q, fmt.Errorf("invalid term '%s', expected %s", term, ProjectQueryHelp)
+		}
+		switch strings.ToLower(key) {
+		case "tag":
+			q.Tag = value
+		case "type":
+			q.ProjectType = value
+		case "stale":
+			days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+			if err != nil || days < 0 {
+				return q, fmt.Errorf("invalid number of days '%s'", value)
+			}
+			q.StaleBefore = now.AddDate(0, 0, -days)
+		default:
+			return q, fmt.Errorf("unknown term '%s', expected %s", key, ProjectQueryHelp)
+		}
+	}
+	return q, nil
+}
+
+// FindProjects returns the registered projects matching q, least recently
+// modified first when looking for stale projects and by name otherwise.
+// Tags and types are compared ignoring case.
+func FindProjects(db *sql.DB, q ProjectQuery) ([]ProjectRecord, error) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+	order := "p.name"
+	if q.Workspace != "" {
+		where = append(where, "w.name = ? COLLATE NOCASE")
+		args = append(args, q.Workspace)
+	}
+	if q.Tag != "" {
+		where = append(where, "p.id IN (SELECT project_id FROM project_tags WHERE tag = ?)")
+		args = append(args, q.Tag)
+	}
+	if q.ProjectType != "" {
+		where = append(where, "p.project_type = ? COLLATE NOCASE")
+		args = append(args, q.ProjectType)
+	}
+	if !q.StaleBefore.IsZero() {
+		where = append(where, "p.date_modified < ?")
+		args = append(args, q.StaleBefore)
+		order = "p.date_modified"
+	}
+	return queryProjects(db, strings.Join(where, " AND "), order, args...)
+}
+
+// WorkspacePaths returns the directory of every registered workspace, by its
+// name in lower case.
+func WorkspacePaths(db *sql.DB) (map[string]string, error) {
+	rows, err := db.Query("SELECT name, path FROM workspaces")
+	if err != nil {
+		return nil, fmt.Errorf("error querying workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	paths := make(map[string]string)
+	for rows.Next() {
+		var name, path string
+		if err := rows.Scan(&name, &path); err != nil {
+			return nil, fmt.Errorf("error querying workspaces: %w", err)
+		}
+		paths[strings.ToLower(name)] = path
+	}
+	return paths, rows.Err()
+}
+
+// saveWorkspace inserts or updates the row of the workspace in workspaceDir and returns its id.
+func saveWorkspace(tx *sql.Tx, workspaceDir string) (int64, error) {
+	workspaceDir = filepath.Clean(workspaceDir)
+	query := `
+	INSERT INTO workspaces (name, path, updated_at) VALUES (?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET path = excluded.path, updated_at = excluded.updated_at;
+	`
+	name := filepath.Base(workspaceDir)
+	if _, err := tx.Exec(query, name, workspaceDir, time.Now()); err != nil {
+		return 0, fmt.Errorf("error saving workspace '%s': %w", name, err)
+	}
+	var id int64
+	if err := tx.QueryRow("SELECT id FROM workspaces WHERE name = ?", name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("error saving workspace '%s': %w", name, err)
+	}
+	return id, nil
+}
+
+// saveProject inserts or updates the row of p and replaces its tags.
+func saveProject(tx *sql.Tx, workspaceID int64, p ProjectRecord) error {
+	query := `
+	INSERT INTO projects (workspace_id, dir, name, alias, project_type, notes, path, git_url, todo_store,
+		date_created, date_modified, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (workspace_id, dir) DO UPDATE SET name = excluded.name, alias = excluded.alias,
+		project_type = excluded.project_type, notes = excluded.notes, path = excluded.path,
+		git_url = excluded.git_url, todo_store = excluded.todo_store, date_created = excluded.date_created,
+		date_modified = excluded.date_modified, updated_at = excluded.updated_at;
+	`
+	if _, err := tx.Exec(query, workspaceID, p.Dir, p.Name, p.Alias, p.ProjectType, strings.Join(p.Notes, "\n"),
+		p.Path, p.GitURL, p.TodoStore, p.DateCreated, p.DateModified, time.Now()); err != nil {
+		return fmt.Errorf("error saving project '%s': %w", p.Name, err)
+	}
+
+	var id int64
+	if err := tx.QueryRow("SELECT id FROM projects WHERE workspace_id = ? AND dir = ?", workspaceID, p.Dir).Scan(&id); err != nil {
+		return fmt.Errorf("error saving project '%s': %w", p.Name, err)
+	}
+	if _, err := tx.Exec("DELETE FROM project_tags WHERE project_id = ?", id); err != nil {
+		return fmt.Errorf("error saving tags of project '%s': %w", p.Name, err)
+	}
+	for _, tag := range p.Tags {
+		tag = strings.TrimSpace(strings.ReplaceAll(tag, tagSeparator, ""))
+		if tag == "" {
+			continue
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO project_tags (project_id, tag) VALUES (?, ?)", id, tag); err != nil {
+			return fmt.Errorf("error saving tags of project '%s': %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// linkTodos points the tasks not linked to a project yet at the registered
+// project with the same project and workspace names.
+func linkTodos(tx *sql.Tx) error {
+	query := `
+	UPDATE todos SET project_id = (
+		SELECT p.id FROM projects p JOIN workspaces w ON w.id = p.workspace_id
+		WHERE p.dir = todos.project_name AND w.name = todos.workspace_name
+	)
+	WHERE project_id IS NULL;
+	`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("error linking tasks to projects: %w", err)
+	}
+	return nil
+}
+
+// queryProjects returns the registered projects matching where, ordered by order.
+func queryProjects(db *sql.DB, where string, order string, args ...interface{}) ([]ProjectRecord, error) {
+	query := `
+	SELECT w.name, p.dir, p.name, COALESCE(p.alias, ''), COALESCE(p.project_type, ''), COALESCE(p.notes, ''),
+		p.path, COALESCE(p.git_url, ''), COALESCE(p.todo_store, ''), p.date_created, p.date_modified,
+		(SELECT GROUP_CONCAT(tag, '` + tagSeparator + `') FROM project_tags WHERE project_id = p.id)
+	FROM projects p JOIN workspaces w ON w.id = p.workspace_id
+	WHERE ` + where + `
+	ORDER BY ` + order + `, w.name`
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying projects: %w", err)
+	}
+	defer rows.Close()
+
+	var projects []ProjectRecord
+	for rows.Next() {
+		var p ProjectRecord
+		var notes string
+		var created, modified sql.NullTime
+		var tags sql.NullString
+		if err := rows.Scan(&p.Workspace, &p.Dir, &p.Name, &p.Alias, &p.ProjectType, &notes, &p.Path,
+			&p.GitURL, &p.TodoStore, &created, &modified, &tags); err != nil {
+			return nil, fmt.Errorf("error querying projects: %w", err)
+		}
+		if notes != "" {
+			p.Notes = strings.Split(notes, "\n")
+		}
+		if tags.Valid && tags.String != "" {
+			p.Tags = strings.Split(tags.String, tagSeparator)
+		}
+		p.DateCreated, p.DateModified = created.Time, modified.Time
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// PrintProjects prints registered projects in a table.
+func PrintProjects(projects []ProjectRecord) {
+	if len(projects) == 0 {
+		fmt.Println("No matching projects found.")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.SetHeader([]string{"No.", "Name", "Type", "Tags", "Modified", "Workspace"})
+	for i, p := range projects {
+		modified := ""
+		if !p.DateModified.IsZero() {
+			modified = p.DateModified.Format("2006-01-02")
+		}
+		table.Append([]string{strconv.Itoa(i + 1), p.Name, p.ProjectType, strings.Join(p.Tags, ", "), modified, p.Workspace})
+	}
+	table.Render()
+}
+
+// Find parses input (see ParseProjectQuery), adds it to scope and prints the
+// matching projects registered in db.
+func Find(db *sql.DB, input string, scope ProjectQuery) {
+	q, err := ParseProjectQuery(input, time.Now())
+	if err != nil {
+		fmt.Println("Invalid query:", err)
+		return
+	}
+	if scope.Workspace != "" {
+		q.Workspace = scope.Workspace
+	}
+	projects, err := FindProjects(db, q)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	PrintProjects(projects)
+}
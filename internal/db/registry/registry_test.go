@@ -0,0 +1,285 @@
+package registry
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/db/migrate"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// openDB returns a migrated in-memory database.
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a database of its own.
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+	if _, err := migrate.Apply(conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// summary describes each project as "workspace/dir name [tags]", with the
+// tags sorted.
+func summary(projects []ProjectRecord) []string {
+	var lines []string
+	for _, p := range projects {
+		tags := append([]string(nil), p.Tags...)
+		sort.Strings(tags)
+		lines = append(lines, fmt.Sprintf("%s/%s %s %v", p.Workspace, p.Dir, p.Name, tags))
+	}
+	return lines
+}
+
+// allProjects returns every registered project, ordered by name.
+func allProjects(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	projects, err := FindProjects(db, ProjectQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary(projects)
+}
+
+// save is a call of SaveWorkspace.
+type save struct {
+	dir      string
+	projects []ProjectRecord
+}
+
+func TestSaveWorkspace(t *testing.T) {
+	alpha := ProjectRecord{Dir: "alpha", Name: "alpha", Tags: []string{"go", "cli"}, Path: "alpha"}
+	beta := ProjectRecord{Dir: "beta", Name: "beta", Path: "beta"}
+	tests := []struct {
+		name  string
+		saves []save
+		want  []string
+	}{
+		{
+			name:  "new workspace",
+			saves: []save{{"/root/ws", []ProjectRecord{alpha, beta}}},
+			want:  []string{"ws/alpha alpha [cli go]", "ws/beta beta []"},
+		},
+		{
+			name: "project removed from the workspace",
+			saves: []save{
+				{"/root/ws", []ProjectRecord{alpha, beta}},
+				{"/root/ws", []ProjectRecord{beta}},
+			},
+			want: []string{"ws/beta beta []"},
+		},
+		{
+			name: "every project removed",
+			saves: []save{
+				{"/root/ws", []ProjectRecord{alpha, beta}},
+				{"/root/ws", nil},
+			},
+			want: nil,
+		},
+		{
+			name: "project updated in place",
+			saves: []save{
+				{"/root/ws", []ProjectRecord{alpha}},
+				{"/root/ws", []ProjectRecord{{Dir: "alpha", Name: "Alpha", Tags: []string{"rust"}, Path: "alpha"}}},
+			},
+			want: []string{"ws/alpha Alpha [rust]"},
+		},
+		{
+			name: "blank and duplicate tags",
+			saves: []save{
+				{"/root/ws", []ProjectRecord{{Dir: "alpha", Name: "alpha", Tags: []string{" go ", "Go", "", "a\x1fb"}, Path: "alpha"}}},
+			},
+			want: []string{"ws/alpha alpha [ab go]"},
+		},
+		{
+			name: "other workspaces are left alone",
+			saves: []save{
+				{"/root/ws", []ProjectRecord{alpha}},
+				{"/root/other", []ProjectRecord{beta}},
+				{"/root/other", nil},
+			},
+			want: []string{"ws/alpha alpha [cli go]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openDB(t)
+			for _, s := range tt.saves {
+				if err := SaveWorkspace(db, s.dir, s.projects); err != nil {
+					t.Fatalf("SaveWorkspace(%s): %v", s.dir, err)
+				}
+			}
+			if got := allProjects(t, db); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("projects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveProject(t *testing.T) {
+	db := openDB(t)
+	alpha := ProjectRecord{Dir: "alpha", Name: "alpha", Path: "alpha"}
+	if err := SaveWorkspace(db, "/root/ws", []ProjectRecord{alpha}); err != nil {
+		t.Fatal(err)
+	}
+	// Saving a single project keeps the others of its workspace.
+	if err := SaveProject(db, "/root/ws", ProjectRecord{Dir: "beta", Name: "beta", Tags: []string{"new"}, Path: "beta"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ws/alpha alpha []", "ws/beta beta [new]"}
+	if got := allProjects(t, db); !reflect.DeepEqual(got, want) {
+		t.Errorf("projects = %q, want %q", got, want)
+	}
+
+	var workspaces int
+	if err := db.QueryRow("SELECT COUNT(*) FROM workspaces").Scan(&workspaces); err != nil {
+		t.Fatal(err)
+	}
+	if workspaces != 1 {
+		t.Errorf("%d workspace rows, want 1", workspaces)
+	}
+}
+
+func TestLinkTodos(t *testing.T) {
+	db := openDB(t)
+	for _, task := range [][2]string{
+		{"alpha", "ws"},    // linked to ws/alpha
+		{"alpha", "other"}, // same project name in another workspace
+		{"gamma", "ws"},    // not a registered project
+	} {
+		if _, err := db.Exec("INSERT INTO todos (description, created_date, project_name, workspace_name) VALUES (?, ?, ?, ?)",
+			task[0]+"@"+task[1], time.Now(), task[0], task[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	linked := func() map[string]bool {
+		t.Helper()
+		rows, err := db.Query("SELECT description, project_id IS NOT NULL FROM todos")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		result := make(map[string]bool)
+		for rows.Next() {
+			var description string
+			var ok bool
+			if err := rows.Scan(&description, &ok); err != nil {
+				t.Fatal(err)
+			}
+			result[description] = ok
+		}
+		return result
+	}
+
+	if err := SaveWorkspace(db, "/root/ws", []ProjectRecord{{Dir: "alpha", Name: "Alpha", Path: "alpha"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"alpha@ws": true, "alpha@other": false, "gamma@ws": false}
+	if got := linked(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after saving ws: linked = %v, want %v", got, want)
+	}
+
+	if err := SaveProject(db, "/root/other", ProjectRecord{Dir: "alpha", Name: "alpha", Path: "alpha"}); err != nil {
+		t.Fatal(err)
+	}
+	want["alpha@other"] = true
+	if got := linked(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after saving other/alpha: linked = %v, want %v", got, want)
+	}
+
+	// Removing a project unlinks its tasks instead of deleting them.
+	if err := SaveWorkspace(db, "/root/ws", nil); err != nil {
+		t.Fatal(err)
+	}
+	want["alpha@ws"] = false
+	if got := linked(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after removing ws/alpha: linked = %v, want %v", got, want)
+	}
+}
+
+func TestFindProjects(t *testing.T) {
+	db := openDB(t)
+	now := time.Now()
+	old := now.AddDate(0, 0, -90)
+	older := now.AddDate(0, 0, -120)
+	if err := SaveWorkspace(db, "/root/ws", []ProjectRecord{
+		{Dir: "cli", Name: "cli", ProjectType: "Coding", Tags: []string{"Go", "tools"}, Path: "cli", DateModified: now},
+		{Dir: "site", Name: "site", ProjectType: "web", Tags: []string{"js"}, Path: "site", DateModified: old},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveWorkspace(db, "/root/other", []ProjectRecord{
+		{Dir: "lib", Name: "lib", ProjectType: "coding", Tags: []string{"go"}, Path: "lib", DateModified: older},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		q    ProjectQuery
+		want []string // workspace/dir, in order
+	}{
+		{name: "everything by name", q: ProjectQuery{}, want: []string{"ws/cli", "other/lib", "ws/site"}},
+		{name: "workspace", q: ProjectQuery{Workspace: "WS"}, want: []string{"ws/cli", "ws/site"}},
+		{name: "tag ignoring case", q: ProjectQuery{Tag: "go"}, want: []string{"ws/cli", "other/lib"}},
+		{name: "type ignoring case", q: ProjectQuery{ProjectType: "CODING"}, want: []string{"ws/cli", "other/lib"}},
+		{name: "stale, least recently modified first", q: ProjectQuery{StaleBefore: now.AddDate(0, 0, -30)}, want: []string{"other/lib", "ws/site"}},
+		{name: "tag in workspace", q: ProjectQuery{Workspace: "other", Tag: "go"}, want: []string{"other/lib"}},
+		{name: "no match", q: ProjectQuery{Tag: "rust"}, want: nil},
+	}
+	for _, tt := range tests {
+		projects, err := FindProjects(db, tt.q)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, p := range projects {
+			got = append(got, p.Workspace+"/"+p.Dir)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FindProjects = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseProjectQuery(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input   string
+		want    ProjectQuery
+		wantErr string
+	}{
+		{input: "", want: ProjectQuery{}},
+		{input: "tag:go type:coding", want: ProjectQuery{Tag: "go", ProjectType: "coding"}},
+		{input: "TAG:go stale:30", want: ProjectQuery{Tag: "go", StaleBefore: now.AddDate(0, 0, -30)}},
+		{input: "stale:7d", want: ProjectQuery{StaleBefore: now.AddDate(0, 0, -7)}},
+		{input: "go", wantErr: "invalid term"},
+		{input: "tag:", wantErr: "invalid term"},
+		{input: "stale:-1", wantErr: "invalid number of days"},
+		{input: "stale:soon", wantErr: "invalid number of days"},
+		{input: "owner:me", wantErr: "unknown term"},
+	}
+	for _, tt := range tests {
+		got, err := ParseProjectQuery(tt.input, now)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseProjectQuery(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseProjectQuery(%q) = %+v, %v; want %+v", tt.input, got, err, tt.want)
+		}
+	}
+}
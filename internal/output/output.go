@@ -0,0 +1,203 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Formats a listing can be written in. Table is the human readable output of
+// each listing's own printer; the others are written by Write.
+const (
+	Table  = "table"
+	JSON   = "json"   // an array of objects
+	NDJSON = "ndjson" // one object per line
+	CSV    = "csv"    // a header row with the field names, then one row per item
+	YAML   = "yaml"   // a sequence of mappings
+)
+
+// FormatHelp lists the formats accepted by CheckFormat.
+const FormatHelp = "table, json, ndjson, csv or yaml"
+
+// CheckFormat returns an error unless format is one of the known formats.
+func CheckFormat(format string) error {
+	switch format {
+	case Table, JSON, NDJSON, CSV, YAML:
+		return nil
+	}
+	return fmt.Errorf("unknown format '%s' (use %s)", format, FormatHelp)
+}
+
+// Listing is a list of items with the same fields, such as todos or projects.
+// Field names are snake_case and stay stable across releases, as scripts
+// depend on them. Values are strings, ints, bools, string slices or nil for
+// an unset value.
+type Listing struct {
+	Fields []string
+	Rows   [][]interface{}
+}
+
+// NewListing returns an empty listing with the given fields.
+func NewListing(fields ...string) *Listing {
+	return &Listing{Fields: fields}
+}
+
+// Add appends an item with one value per field.
+func (l *Listing) Add(values ...interface{}) {
+	if len(values) != len(l.Fields) {
+		panic(fmt.Sprintf("output: %d values for %d fields", len(values), len(l.Fields)))
+	}
+	l.Rows = append(l.Rows, values)
+}
+
+// Write writes the listing to w in format, which must not be Table.
+func Write(w io.Writer, format string, l *Listing) error {
+	switch format {
+	case JSON:
+		return writeJSON(w, l)
+	case NDJSON:
+		return writeNDJSON(w, l)
+	case CSV:
+		return writeCSV(w, l)
+	case YAML:
+		return writeYAML(w, l)
+	}
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+	return fmt.Errorf("the %s format is printed by the listing itself", format)
+}
+
+// object encodes a row as a JSON object with the fields in listing order.
+func (l *Listing) object(row []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range l.Fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := encode(name, row[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(strconv.Quote(name))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// encode returns the JSON encoding of the value of the field called name. A
+// nil string slice is an empty list rather than an unset value.
+func encode(name string, value interface{}) ([]byte, error) {
+	if v, ok := value.([]string); ok && v == nil {
+		value = []string{}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return nil, fmt.Errorf("error encoding field '%s': %w", name, err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func writeJSON(w io.Writer, l *Listing) error {
+	if len(l.Rows) == 0 {
+		_, err := io.WriteString(w, "[]\n")
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("[\n")
+	for i, row := range l.Rows {
+		obj, err := l.object(row)
+		if err != nil {
+			return err
+		}
+		buf.WriteString("  ")
+		buf.Write(obj)
+		if i < len(l.Rows)-1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("]\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeNDJSON(w io.Writer, l *Listing) error {
+	for _, row := range l.Rows {
+		obj, err := l.object(row)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(obj, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCSV writes string slices as cells holding their JSON encoding, e.g.
+// ["a","b,c"], so items containing commas can be told apart, and unset values
+// as empty cells.
+func writeCSV(w io.Writer, l *Listing) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(l.Fields); err != nil {
+		return err
+	}
+	for _, row := range l.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				record[i] = v
+			case []string:
+				cell, err := encode(l.Fields[i], v)
+				if err != nil {
+					return err
+				}
+				record[i] = string(cell)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeYAML writes each value in its JSON encoding, which YAML reads as a
+// quoted string, a number, a boolean, null or a flow sequence.
+func writeYAML(w io.Writer, l *Listing) error {
+	if len(l.Rows) == 0 {
+		_, err := io.WriteString(w, "[]\n")
+		return err
+	}
+	var buf bytes.Buffer
+	for _, row := range l.Rows {
+		for i, name := range l.Fields {
+			value, err := encode(name, row[i])
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				buf.WriteString("- ")
+			} else {
+				buf.WriteString("  ")
+			}
+			fmt.Fprintf(&buf, "%s: %s\n", name, value)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,112 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testListing has a row with every kind of value and a row of unset values.
+func testListing() *Listing {
+	l := NewListing("name", "count", "done", "tags", "note")
+	l.Add("a \"quoted\", name", 3, true, []string{"x,y", "z"}, "<b>")
+	l.Add("b", nil, false, []string(nil), nil)
+	return l
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{JSON, `[
+  {"name":"a \"quoted\", name","count":3,"done":true,"tags":["x,y","z"],"note":"<b>"},
+  {"name":"b","count":null,"done":false,"tags":[],"note":null}
+]
+`},
+		{NDJSON, `{"name":"a \"quoted\", name","count":3,"done":true,"tags":["x,y","z"],"note":"<b>"}
+{"name":"b","count":null,"done":false,"tags":[],"note":null}
+`},
+		{CSV, `name,count,done,tags,note
+"a ""quoted"", name",3,true,"[""x,y"",""z""]",<b>
+b,,false,[],
+`},
+		{YAML, `- name: "a \"quoted\", name"
+  count: 3
+  done: true
+  tags: ["x,y","z"]
+  note: "<b>"
+- name: "b"
+  count: null
+  done: false
+  tags: []
+  note: null
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.format, testListing()); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Write(%s) =\n%s\nwant\n%s", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	for format, want := range map[string]string{JSON: "[]\n", NDJSON: "", CSV: "name\n", YAML: "[]\n"} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, NewListing("name")); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("Write(%s) of an empty listing = %q, want %q", format, buf.String(), want)
+		}
+	}
+}
+
+func TestWriteJSONParses(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, JSON, testListing()); err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["name"] != `a "quoted", name` {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+}
+
+func TestWriteCSVListsRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, CSV, testListing()); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tags []string
+	if err := json.Unmarshal([]byte(records[1][3]), &tags); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x,y", "z"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %q, want %q", tags, want)
+	}
+}
+
+func TestWriteFormatErrors(t *testing.T) {
+	for _, format := range []string{Table, "xml", ""} {
+		if err := Write(&bytes.Buffer{}, format, testListing()); err == nil {
+			t.Errorf("Write(%q) succeeded", format)
+		}
+	}
+}
@@ -1,22 +1,28 @@
 package project
 
 import (
+	"database/sql"
 	"fmt"
 
 	"github.com/johnjallday/flow-workspace/internal/todo"
 )
 
-func AddTodoToProject(projectDir, description, dueDate string) error {
+// AddTodoToProject adds a task to the project's task store and returns the new task's ID.
+func AddTodoToProject(conn *sql.DB, projectDir, description, dueDate, priority string) (string, error) {
 	if projectDir == "" || description == "" {
-		return fmt.Errorf("project-dir and description are required")
+		return "", fmt.Errorf("project-dir and description are required")
 	}
 
 	todoFile := projectDir + "/todo.md"
-	service := todo.NewFileTodoService(todoFile)
+	service, err := todo.NewTodoService(conn, todoFile)
+	if err != nil {
+		return "", err
+	}
 
-	if err := service.AddTodo(description, dueDate); err != nil {
-		return fmt.Errorf("failed to add todo: %w", err)
+	id, err := service.AddTodo(description, dueDate, priority)
+	if err != nil {
+		return "", fmt.Errorf("failed to add todo: %w", err)
 	}
 
-	return nil
+	return id, nil
 }
@@ -13,13 +13,18 @@ import (
 // ImportProject imports a project from the given directory.
 // If project_info.toml does not exist, it creates one with default values,
 // and automatically sets the project_type based on file extensions.
-func ImportProject(projectDir string) error {
+// The project is then registered in the database at dbPath.
+func ImportProject(dbPath string, projectDir string) error {
 	metaFile := filepath.Join(projectDir, "project_info.toml")
 
 	// Check if the file already exists.
 	if _, err := os.Stat(metaFile); err == nil {
 		fmt.Printf("Project info already exists at %s\n", metaFile)
-		return nil
+		proj, err := LoadProjectInfo(metaFile)
+		if err != nil {
+			return err
+		}
+		return RegisterProject(dbPath, projectDir, proj)
 	} else if !os.IsNotExist(err) {
 		return fmt.Errorf("error checking for project_info.toml: %v", err)
 	}
@@ -108,5 +113,5 @@ func ImportProject(projectDir string) error {
 	}
 
 	fmt.Printf("Created new project_info.toml at %s with project type '%s'\n", metaFile, proj.ProjectType)
-	return nil
+	return RegisterProject(dbPath, projectDir, &proj)
 }
@@ -2,25 +2,34 @@ package project
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/johnjallday/flow-workspace/internal/db/registry"
+	db "github.com/johnjallday/flow-workspace/internal/db/todo"
 )
 
 // Project represents a single project's metadata from project_info.toml.
 type Project struct {
-	Name         string        `toml:"name"`
-	Alias        string        `toml:"alias"`
-	ProjectType  string        `toml:"project_type"`
-	Tags         []string      `toml:"tags"`
-	DateCreated  time.Time     `toml:"date_created"`
-	DateModified time.Time     `toml:"date_modified"`
-	Notes        []string      `toml:"notes"`
-	Path         string        `toml:"path"`
-	GitURL       string        `toml:"git_url,omitempty"`
-	MusicDetails *MusicDetails `toml:"music_details,omitempty"`
+	Name             string        `toml:"name"`
+	Alias            string        `toml:"alias"`
+	ProjectType      string        `toml:"project_type"`
+	Tags             []string      `toml:"tags"`
+	DateCreated      time.Time     `toml:"date_created"`
+	DateModified     time.Time     `toml:"date_modified"`
+	Notes            []string      `toml:"notes"`
+	Path             string        `toml:"path"`
+	GitURL           string        `toml:"git_url,omitempty"`
+	TodoStore        string        `toml:"todo_store,omitempty"`         // "file" (default) or "sqlite"
+	TodoSync         bool          `toml:"todo_sync,omitempty"`          // sync todo.md with the database
+	Archive          string        `toml:"archive,omitempty"`            // overrides the archive policy of settings.toml
+	ArchiveAfterDays *int          `toml:"archive_after_days,omitempty"` // (see config.ArchivePolicy)
+	ArchiveKeepLast  *int          `toml:"archive_keep_last,omitempty"`
+	ArchiveQuiet     *bool         `toml:"archive_quiet,omitempty"`
+	MusicDetails     *MusicDetails `toml:"music_details,omitempty"`
 }
 
 // LoadProjectInfo reads and parses a project_info.toml file into a Project.
@@ -58,7 +67,7 @@ func LoadProjectInfo(filename string) (*Project, error) {
 
 	// If it's newer than what's recorded, update DateModified
 	if latestFileTime.After(proj.DateModified) {
-		fmt.Printf("Updating DateModified for project '%s' to %v\n", proj.Name, latestFileTime)
+		log.Printf("Updating DateModified for project '%s' to %v\n", proj.Name, latestFileTime)
 		proj.DateModified = latestFileTime
 
 		// Save the updated project info back to the file
@@ -108,3 +117,32 @@ func saveProjectInfo(filename string, proj *Project) error {
 
 	return nil
 }
+
+// Record returns the row of the project in projectDir for the projects table.
+func (p Project) Record(projectDir string) registry.ProjectRecord {
+	projectDir = filepath.Clean(projectDir)
+	return registry.ProjectRecord{
+		Workspace:    filepath.Base(filepath.Dir(projectDir)),
+		Dir:          filepath.Base(projectDir),
+		Name:         p.Name,
+		Alias:        p.Alias,
+		ProjectType:  p.ProjectType,
+		Tags:         p.Tags,
+		Notes:        p.Notes,
+		Path:         projectDir,
+		GitURL:       p.GitURL,
+		TodoStore:    p.TodoStore,
+		DateCreated:  p.DateCreated,
+		DateModified: p.DateModified,
+	}
+}
+
+// RegisterProject records the project in projectDir in the database at dbPath.
+func RegisterProject(dbPath string, projectDir string, proj *Project) error {
+	conn, err := db.InitDB(dbPath)
+	if err != nil {
+		return fmt.Errorf("error connecting to db: %w", err)
+	}
+	defer conn.Close()
+	return registry.SaveProject(conn, filepath.Dir(filepath.Clean(projectDir)), proj.Record(projectDir))
+}
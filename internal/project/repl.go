@@ -1,55 +1,70 @@
 package project
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
-	"strconv"
 	"strings"
 
+	"github.com/johnjallday/flow-workspace/internal/config"
 	db "github.com/johnjallday/flow-workspace/internal/db/todo"
+	"github.com/johnjallday/flow-workspace/internal/shell"
 	"github.com/johnjallday/flow-workspace/internal/todo"
 )
 
 // StartProjectREPL starts an interactive REPL for a single project directory.
-func StartProjectREPL(dbPath string, projectDir string) {
-	coderPath := "/Users/jj/Workspace/johnj-programming/gorani-coder/main"
-	reader := bufio.NewReader(os.Stdin)
+func StartProjectREPL(cfg *config.Config, projectDir string) {
+	dbPath := cfg.DBPath
+	coderPath := cfg.AgentPath("coder")
 
 	mydb, err := db.InitDB(dbPath)
 	if err != nil {
 		fmt.Println("Error connecting to db:", err)
 		return
 	}
+	defer mydb.Close()
 
 	todoFile := filepath.Join(projectDir, "todo.md")
-	todo.MigrateFinishedTodos(todoFile, mydb)
+	service, err := todo.NewTodoService(mydb, todoFile)
+	if err != nil {
+		fmt.Println("Error opening todos:", err)
+		return
+	}
+	// Archive finished todos; the report is shown once the screen is cleared.
+	policy := todo.ProjectArchivePolicy(cfg.ArchivePolicy(), projectDir)
+	archived, err := todo.ArchiveFinishedTodos(service, todoFile, mydb, policy, false)
+	if err != nil {
+		fmt.Println("Error archiving finished todos:", err)
+	}
+	if policy.Quiet {
+		archived = nil
+	}
 
-	for {
-		clearScreen()
+	sh := shell.New("Project REPL", "project", fmt.Sprintf("\n[project:%s] >> ", filepath.Base(projectDir)))
+	sh.Pause = true
+
+	// The tasks of the project and the ones listed on screen, which task
+	// numbers refer to: those matching filter, or else the ongoing ones if
+	// there are any.
+	var todos, listed []todo.Todo
+	var filter string
+	metaFile := filepath.Join(projectDir, "project_info.toml")
+	sh.Before = func() {
+		shell.ClearScreen()
+		todo.PrintArchiveReport(archived, false)
+		archived = nil
 
-		// Determine the metadata file path.
-		metaFile := filepath.Join(projectDir, "project_info.toml")
-		var proj *Project
 		proj, err := LoadProjectInfo(metaFile)
 		if err != nil {
 			if os.IsNotExist(err) {
 				fmt.Println("project_info.toml not found.")
-				fmt.Print("Would you like to import this directory? (y/n): ")
-				answer, _ := reader.ReadString('\n')
-				answer = strings.TrimSpace(strings.ToLower(answer))
-				if answer == "y" || answer == "yes" {
-					if err := ImportProject(projectDir); err != nil {
+				if sh.Confirm("Would you like to import this directory?") {
+					if err := ImportProject(dbPath, projectDir); err != nil {
 						fmt.Printf("Error importing project: %v\n", err)
-					} else {
+					} else if proj, err = LoadProjectInfo(metaFile); err != nil {
 						// Try to load project info again.
-						proj, err = LoadProjectInfo(metaFile)
-						if err != nil {
-							fmt.Printf("Error loading project info after import: %v\n", err)
-						}
+						fmt.Printf("Error loading project info after import: %v\n", err)
 					}
 				}
 			} else {
@@ -57,184 +72,217 @@ func StartProjectREPL(dbPath string, projectDir string) {
 			}
 		}
 		if proj != nil {
-			printProjectInfo(proj)
+			PrintProjectInfo(proj)
+		}
+
+		// Pick up changes made in an editor or in a database-backed view.
+		if proj != nil && proj.TodoSync {
+			if result, err := todo.SyncProject(mydb, todoFile, todo.PromptSyncConflict(sh)); err != nil {
+				fmt.Println("Error syncing todos:", err)
+			} else if len(result.Conflicts) > 0 {
+				todo.PrintSyncResult(result)
+			}
 		}
 
 		// Load and print todos.
-		service := todo.NewFileTodoService(todoFile)
-		todos, err := service.ListTodos()
-		if err != nil {
+		if todos, err = service.ListTodos(); err != nil {
+			listed = nil
 			fmt.Printf("Error loading todos: %v\n", err)
 		} else {
+			listed = todos
 			ongoingTodos := todo.FilterTodosByOngoing(todos)
-			if len(ongoingTodos) == 0 {
+			switch {
+			case filter != "":
+				listed = todo.FilterTodos(todos, filter)
+				fmt.Printf("Tasks matching \"%s\" (use filter without a filter to show the ongoing ones):\n", filter)
+			case len(ongoingTodos) == 0:
 				fmt.Println("No ongoing tasks found.")
-				todo.PrintTodos(todos)
-			} else {
-				todo.PrintTodos(ongoingTodos)
+			default:
+				listed = ongoingTodos
 			}
+			todo.PrintTodos(listed)
 		}
+		sh.PrintHelp()
+	}
 
-		printProjectHelp()
-		fmt.Println("Enter a command:")
-
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Println("Error reading input:", err)
-			return
-		}
-		line = strings.TrimSpace(line)
-
-		switch strings.ToLower(line) {
-		case "implement":
-			implementTodo(service, coderPath, reader)
-		case "finish":
-			finishTodo(service, coderPath, reader)
-		case "edit":
-			if err := editProjectInfo(metaFile); err != nil {
-				fmt.Printf("Error editing project info: %v\n", err)
-			}
-			fmt.Println("Press Enter to continue...")
-			reader.ReadString('\n')
-		case "todo":
-			todo.StartTodoREPL(dbPath, todoFile)
-			return
-		case "add-todo":
-			fmt.Print("Enter todo description: ")
-			description, _ := reader.ReadString('\n')
-			description = strings.TrimSpace(description)
-
-			fmt.Print("Enter due date (YYYY-MM-DD) or leave blank: ")
-			dueDate, _ := reader.ReadString('\n')
-			dueDate = strings.TrimSpace(dueDate)
-
-			// Reuse the same business logic
-			err := AddTodoToProject(projectDir, description, dueDate)
-			if err != nil {
-				fmt.Printf("Error adding todo: %v\n", err)
-			} else {
-				fmt.Println("Todo added successfully!")
-			}
-
-			fmt.Println("Press Enter to continue...")
-			reader.ReadString('\n')
-		case "edit-todo":
-			todos, err := service.ListTodos()
-			if err != nil {
-				fmt.Printf("Error loading todos: %v\n", err)
-				break
-			}
-
-			if len(todos) == 0 {
-				fmt.Println("No tasks available to edit.")
-				break
-			}
-
-			// Prompt user to pick a todo by number
-			fmt.Print("Enter the number of the todo to edit: ")
-			input, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Printf("Error reading input: %v\n", err)
-				break
-			}
-			input = strings.TrimSpace(input)
-			index, err := strconv.Atoi(input)
-			if err != nil || index < 1 || index > len(todos) {
-				fmt.Println("Invalid todo number.")
-				break
-			}
-			selectedIndex := index - 1
-
-			// Prompt for new values (leave blank to keep current)
-			fmt.Printf("Current description: %s\n", todos[selectedIndex].Description)
-			fmt.Print("Enter new description (leave blank to keep current): ")
-			newDescription, _ := reader.ReadString('\n')
-			newDescription = strings.TrimSpace(newDescription)
-
-			fmt.Printf("Current due date: %s\n", todos[selectedIndex].DueDate.Format("2006-01-02"))
-			fmt.Print("Enter new due date (YYYY-MM-DD, leave blank to keep current): ")
-			newDueDate, _ := reader.ReadString('\n')
-			newDueDate = strings.TrimSpace(newDueDate)
-
-			fmt.Print("Enter new status (ongoing/complete, leave blank to keep current): ")
-			newStatus, _ := reader.ReadString('\n')
-			newStatus = strings.TrimSpace(newStatus)
-
-			// Call the service to edit the todo
-			err = service.EditTodo(selectedIndex, newDescription, newDueDate, newStatus)
-			if err != nil {
-				fmt.Printf("Error editing todo: %v\n", err)
-			} else {
-				fmt.Println("Todo edited successfully.")
-			}
-
-			fmt.Println("Press Enter to continue...")
-			reader.ReadString('\n')
-
-		case "delete-todo":
-			todos, err := service.ListTodos()
-			if err != nil {
-				fmt.Printf("Error loading todos: %v\n", err)
-				break
-			}
-
-			if len(todos) == 0 {
-				fmt.Println("No tasks available to delete.")
-				break
-			}
-
-			todo.PrintTodos(todos)
-
-			fmt.Print("Enter the number of the todo to delete: ")
-			input, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Println("Error reading input:", err)
-				break
-			}
-			input = strings.TrimSpace(input)
-			index, err := strconv.Atoi(input)
-			if err != nil || index < 1 || index > len(todos) {
-				fmt.Println("Invalid task number.")
-				break
-			}
-			selectedIndex := index - 1
-
-			// Confirm deletion
-			fmt.Printf("Are you sure you want to delete task #%d: \"%s\"? (y/n): ", index, todos[selectedIndex].Description)
-			confirm, _ := reader.ReadString('\n')
-			confirm = strings.TrimSpace(strings.ToLower(confirm))
-			if confirm != "y" && confirm != "yes" {
-				fmt.Println("Delete canceled.")
-				break
-			}
-
-			if err := service.DeleteTodo(selectedIndex); err != nil {
-				fmt.Printf("Error deleting task: %v\n", err)
-			} else {
+	sh.Register(
+		&shell.Command{
+			Name: "todo",
+			Help: "Open the TODO REPL for this project (exits Project REPL)",
+			Run: func(c *shell.Context) error {
+				todo.StartTodoREPL(cfg, todoFile)
+				return shell.ErrExit
+			},
+		},
+		&shell.Command{
+			Name: "add-todo",
+			Args: "[description]",
+			Help: "Add a new TODO to this project (#every:<rule> makes it recurring)",
+			Run: func(c *shell.Context) error {
+				description, err := c.Arg("Enter todo description: ")
+				if err != nil {
+					return err
+				}
+				dueDate, err := c.Shell.Ask(fmt.Sprintf("Enter due date (%s) or leave blank: ", todo.DateInputHelp))
+				if err != nil {
+					return err
+				}
+				priority, err := c.Shell.Ask("Enter priority (1-4 or A-D) or leave blank: ")
+				if err != nil {
+					return err
+				}
+				// Reuse the same business logic
+				id, err := AddTodoToProject(mydb, projectDir, description, dueDate, priority)
+				if err != nil {
+					return fmt.Errorf("adding todo: %w", err)
+				}
+				fmt.Printf("Todo %s added successfully!\n", id)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name:     "edit-todo",
+			Args:     "[task]",
+			Complete: func() []string { return todo.TaskNumbers(listed) },
+			Help:     "Edit a TODO item in this project",
+			Run: func(c *shell.Context) error {
+				id, err := todo.TaskArg(c, listed, todos, "Enter the number or ID of the todo to edit: ")
+				if err != nil {
+					return err
+				}
+				return todo.EditTask(c.Shell, service, todos, id)
+			},
+		},
+		&shell.Command{
+			Name:     "delete-todo",
+			Args:     "[task]",
+			Complete: func() []string { return todo.TaskNumbers(listed) },
+			Help:     "Delete a TODO item in this project",
+			Exact:    true,
+			Run: func(c *shell.Context) error {
+				if c.Rest == "" && len(listed) > 0 {
+					todo.PrintTodos(listed)
+				}
+				id, err := todo.TaskArg(c, listed, todos, "Enter the number or ID of the todo to delete: ")
+				if err != nil {
+					return err
+				}
+				// Confirm deletion
+				question := fmt.Sprintf("Are you sure you want to delete task %s: \"%s\"?", id, todos[todo.FindTodoByID(todos, id)].Description)
+				if !c.Shell.Confirm(question) {
+					fmt.Println("Delete canceled.")
+					return nil
+				}
+				if err := service.DeleteTodoByID(id); err != nil {
+					return fmt.Errorf("deleting task: %w", err)
+				}
 				fmt.Println("Task deleted successfully.")
-			}
-
-			fmt.Println("Press Enter to continue...")
-			reader.ReadString('\n')
-		case "weekly":
-			fmt.Println("Running weekly review...")
-			todo.ReviewWeekly(todos)
-			fmt.Print("Press Enter to continue...")
-			_, _ = reader.ReadString('\n')
-
-		case "exit":
-			fmt.Println("Exiting Project REPL. Goodbye!")
-			return
-		default:
-			fmt.Println("Unknown command. Type 'help' for available commands.")
-			fmt.Println("Press Enter to continue...")
-			reader.ReadString('\n')
-		}
-	}
+				return nil
+			},
+		},
+		&shell.Command{
+			Name:     "note",
+			Args:     "[task]",
+			Complete: func() []string { return todo.TaskNumbers(listed) },
+			Help:     "View or replace the notes of a TODO",
+			Run: func(c *shell.Context) error {
+				id, err := todo.TaskArg(c, listed, todos, "Enter the number or ID of the todo: ")
+				if err != nil {
+					return err
+				}
+				return todo.EditNotes(c.Shell, service, todos[todo.FindTodoByID(todos, id)])
+			},
+		},
+		&shell.Command{
+			Name: "filter",
+			Args: "[filter]",
+			Help: "List only TODOs matching #label, @context, key:value or text; no filter lists the ongoing ones",
+			Run: func(c *shell.Context) error {
+				query, err := c.Arg("Enter filter (#label @context key:value text), or leave empty to list the ongoing TODOs: ")
+				if err != nil {
+					return err
+				}
+				filter = strings.TrimSpace(query)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "archive",
+			Help: "Browse archived TODOs of this project and restore one",
+			Run: func(c *shell.Context) error {
+				todo.BrowseArchive(mydb, c.Shell, todo.ProjectArchiveScope(projectDir), func(todo.ArchivedTodo) (string, error) {
+					return projectDir, nil
+				})
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "archive-finished",
+			Help: "Archive the finished TODOs selected by the archive policy now",
+			Run: func(c *shell.Context) error {
+				archived, err := todo.ArchiveFinishedTodos(service, todoFile, mydb, policy, true)
+				if err != nil {
+					fmt.Println("Error archiving finished todos:", err)
+				}
+				todo.PrintArchiveReport(archived, true)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "sync",
+			Help: "Sync todo.md with the tasks stored in the database",
+			Run: func(c *shell.Context) error {
+				result, err := todo.SyncProject(mydb, todoFile, todo.PromptSyncConflict(c.Shell))
+				if err != nil {
+					return fmt.Errorf("syncing todos: %w", err)
+				}
+				todo.PrintSyncResult(result)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "weekly",
+			Help: "Run a weekly review of project tasks",
+			Run: func(c *shell.Context) error {
+				fmt.Println("Running weekly review...")
+				todo.ReviewWeekly(todos, cfg.WeekStartDay())
+				return nil
+			},
+		},
+		&shell.Command{
+			Name:     "implement",
+			Args:     "[task]",
+			Complete: func() []string { return todo.TaskNumbers(listed) },
+			Help:     "Implement a todo",
+			Run: func(c *shell.Context) error {
+				return executeTodoCommand(c, service, listed, todos, coderPath, "ongoing", "implement", "create")
+			},
+		},
+		&shell.Command{
+			Name:     "finish",
+			Args:     "[task]",
+			Complete: func() []string { return todo.TaskNumbers(listed) },
+			Help:     "Mark a todo as complete",
+			Run: func(c *shell.Context) error {
+				return executeTodoCommand(c, service, listed, todos, coderPath, "complete", "implement", "merge")
+			},
+		},
+		&shell.Command{
+			Name: "edit",
+			Help: "Edit project info (tags, notes, name, alias, project type)",
+			Run: func(c *shell.Context) error {
+				if err := editProjectInfo(metaFile, c.Shell); err != nil {
+					return fmt.Errorf("editing project info: %w", err)
+				}
+				return nil
+			},
+		},
+	)
+	sh.Run()
 }
 
-// printProjectInfo displays key project metadata on the screen.
-func printProjectInfo(proj *Project) {
+// PrintProjectInfo displays key project metadata on the screen.
+func PrintProjectInfo(proj *Project) {
 	fmt.Println("====================================")
 	fmt.Println("Project Info:")
 	fmt.Println("Name:         ", proj.Name)
@@ -250,117 +298,79 @@ func printProjectInfo(proj *Project) {
 	fmt.Println("====================================")
 }
 
-func printProjectHelp() {
-	fmt.Println(`Available commands (Project REPL):
-  todo       - Open the TODO REPL for this project (exits Project REPL)
-  add-todo   - Add a new TODO to this project
-  edit-todo  - Edit a TODO item in this project
-  delete-todo- Delete a TODO item in this project
-  weekly     - Run a weekly review of project tasks
-  implement  - Implement a todo
-  finish     - Mark a todo as complete
-  edit       - Edit project info (tags, notes, name, alias, project type)
-  exit       - Exit the Project REPL`)
-}
-
-func implementTodo(service *todo.FileTodoService, coderPath string, reader *bufio.Reader) {
-	executeTodoCommand(service, coderPath, reader, "ongoing", "implement", "create")
-}
-
-func finishTodo(service *todo.FileTodoService, coderPath string, reader *bufio.Reader) {
-	executeTodoCommand(service, coderPath, reader, "complete", "implement", "merge")
-}
-
-func executeTodoCommand(service *todo.FileTodoService, coderPath string, reader *bufio.Reader, status string, command string, action string) {
-	todos, err := service.ListTodos()
-	if err != nil {
-		fmt.Printf("Error loading todos: %v\n", err)
-		return
-	}
+// executeTodoCommand sets the status of the task given as argument, or else
+// the only ongoing one, and runs the coder agent with command and action.
+// Task numbers refer to listed, the tasks shown on screen.
+func executeTodoCommand(c *shell.Context, service todo.TodoService, listed []todo.Todo, todos []todo.Todo, coderPath string, status string, command string, action string) error {
 	if len(todos) == 0 {
 		fmt.Println("No todos available.")
-		return
+		return nil
 	}
 
 	// Attempt to automatically select the todo if exactly one is ongoing.
+	// Blocked tasks are never picked automatically.
 	selectedIndex := -1
 	ongoingCount := 0
 	for i, t := range todos {
-		if t.Ongoing {
+		if t.Ongoing && !t.Blocked {
 			ongoingCount++
 			selectedIndex = i
 		}
 	}
 
-	// If exactly one ongoing task exists, prompt user whether to proceed.
-	if ongoingCount == 1 {
-		fmt.Printf("Automatically selecting the only ongoing task: %d. Proceed? (y/n): ", selectedIndex+1)
-		answer, _ := reader.ReadString('\n')
-		answer = strings.TrimSpace(strings.ToLower(answer))
-		if answer != "y" && answer != "yes" {
-			// User did not want to use the automatic selection; prompt for manual input.
-			fmt.Print("Enter the number of the todo: ")
-			input, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Printf("Error reading input: %v\n", err)
-				return
-			}
-			input = strings.TrimSpace(input)
-			index, err := strconv.Atoi(input)
-			if err != nil || index < 1 || index > len(todos) {
-				fmt.Println("Invalid todo number.")
-				return
-			}
-			selectedIndex = index - 1
-		}
-	} else {
-		// If there isn't exactly one ongoing task, prompt the user.
-		fmt.Print("Enter the number of the todo: ")
-		input, err := reader.ReadString('\n')
+	// If exactly one ongoing task exists and none was given, prompt user whether to proceed.
+	if c.Rest != "" || ongoingCount != 1 || !c.Shell.Confirm(fmt.Sprintf(
+		"Automatically selecting the only ongoing task %s: \"%s\". Proceed?", todos[selectedIndex].ID, todos[selectedIndex].Description)) {
+		id, err := todo.TaskArg(c, listed, todos, "Enter the number or ID of the todo: ")
 		if err != nil {
-			fmt.Printf("Error reading input: %v\n", err)
-			return
+			return err
 		}
-		input = strings.TrimSpace(input)
-		index, err := strconv.Atoi(input)
-		if err != nil || index < 1 || index > len(todos) {
-			fmt.Println("Invalid todo number.")
-			return
+		selectedIndex = todo.FindTodoByID(todos, id)
+	}
+
+	// Starting work on a task that waits for another one needs confirmation.
+	if command == "implement" && status == "ongoing" && todos[selectedIndex].Blocked {
+		question := fmt.Sprintf("Task %s is blocked by %s. Implement it anyway?",
+			todos[selectedIndex].ID, strings.Join(todos[selectedIndex].BlockedBy, ", "))
+		if !c.Shell.Confirm(question) {
+			fmt.Println("Implement canceled.")
+			return nil
+		}
+	}
+
+	// Completing a task with open subtasks asks whether to complete them too.
+	if status == "complete" {
+		subtaskIDs, ok := todo.PromptCompleteSubtasks(c.Shell, todos, todos[selectedIndex].ID)
+		if !ok {
+			return nil
+		}
+		for _, subID := range subtaskIDs {
+			if err := service.CompleteTodoByID(subID); err != nil {
+				fmt.Printf("Error completing subtask: %v\n", err)
+			}
 		}
-		selectedIndex = index - 1
 	}
 
 	// Update the selected todo with the new status.
-	if err := service.EditTodo(selectedIndex, "", "", status); err != nil {
-		fmt.Printf("Error updating todo: %v\n", err)
-		return
+	if err := service.EditTodoByID(todos[selectedIndex].ID, "", "", status, ""); err != nil {
+		return fmt.Errorf("updating todo: %w", err)
 	}
 
 	fmt.Printf("Todo updated successfully to %s!\n", status)
 	description := todos[selectedIndex].Description
 	formattedDescription := strings.ReplaceAll(description, " ", "-")
 
+	if coderPath == "" {
+		fmt.Println("No coder agent configured; set [agents] coder in settings.toml.")
+		return nil
+	}
+
 	cmd := exec.Command(coderPath, command, action, formattedDescription)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		fmt.Printf("Error executing command: %v\n", err)
-	} else {
-		fmt.Printf("Command '%s %s' executed successfully!\n", command, action)
+		return fmt.Errorf("executing command: %w", err)
 	}
-}
-
-func clearScreen() {
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "linux", "darwin":
-		cmd = exec.Command("clear")
-	case "windows":
-		cmd = exec.Command("cmd", "/c", "cls")
-	default:
-		fmt.Println("CLS for", runtime.GOOS, "not implemented")
-		return
-	}
-	cmd.Stdout = os.Stdout
-	cmd.Run()
+	fmt.Printf("Command '%s %s' executed successfully!\n", command, action)
+	return nil
 }
@@ -1,26 +1,26 @@
 package project
 
 import (
-	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/johnjallday/flow-workspace/internal/shell"
 )
 
 // editProjectInfo loads the project metadata from the given filename,
 // allows the user to interactively edit the name, alias, project type, notes, and tags,
 // and then saves the changes back to the file.
-func editProjectInfo(filename string) error {
+func editProjectInfo(filename string, sh *shell.Shell) error {
 	// Load the project metadata.
 	proj, err := LoadProjectInfo(filename)
 	if err != nil {
 		return err
 	}
 
-	reader := bufio.NewReader(os.Stdin)
 	for {
 		// Display current project info.
 		fmt.Println("Current Project Info:")
@@ -30,9 +30,7 @@ func editProjectInfo(filename string) error {
 		fmt.Println("4) Notes        :", strings.Join(proj.Notes, ", "))
 		fmt.Println("5) Tags         :", strings.Join(proj.Tags, ", "))
 		fmt.Println("6) Finish editing")
-		fmt.Print("Enter option number to edit: ")
-
-		option, err := reader.ReadString('\n')
+		option, err := sh.Ask("Enter option number to edit: ")
 		if err != nil {
 			return fmt.Errorf("error reading option: %v", err)
 		}
@@ -44,40 +42,35 @@ func editProjectInfo(filename string) error {
 
 		switch option {
 		case "1":
-			fmt.Print("Enter new name: ")
-			newVal, err := reader.ReadString('\n')
+			newVal, err := sh.Ask("Enter new name: ")
 			if err != nil {
 				return fmt.Errorf("error reading name: %v", err)
 			}
 			proj.Name = strings.TrimSpace(newVal)
 		case "2":
-			fmt.Print("Enter new alias: ")
-			newVal, err := reader.ReadString('\n')
+			newVal, err := sh.Ask("Enter new alias: ")
 			if err != nil {
 				return fmt.Errorf("error reading alias: %v", err)
 			}
 			proj.Alias = strings.TrimSpace(newVal)
 		case "3":
-			fmt.Print("Enter new project type: ")
-			newVal, err := reader.ReadString('\n')
+			newVal, err := sh.Ask("Enter new project type: ")
 			if err != nil {
 				return fmt.Errorf("error reading project type: %v", err)
 			}
 			proj.ProjectType = strings.TrimSpace(newVal)
 		case "4":
-			fmt.Print("Enter new notes (comma separated): ")
-			newVal, err := reader.ReadString('\n')
+			newVal, err := sh.Ask("Enter new notes (comma separated): ")
 			if err != nil {
 				return fmt.Errorf("error reading notes: %v", err)
 			}
-			proj.Notes = parseList(newVal)
+			proj.Notes = ParseList(newVal)
 		case "5":
-			fmt.Print("Enter new tags (comma separated): ")
-			newVal, err := reader.ReadString('\n')
+			newVal, err := sh.Ask("Enter new tags (comma separated): ")
 			if err != nil {
 				return fmt.Errorf("error reading tags: %v", err)
 			}
-			proj.Tags = parseList(newVal)
+			proj.Tags = ParseList(newVal)
 		default:
 			fmt.Println("Invalid option. Please choose a valid number.")
 		}
@@ -102,8 +95,52 @@ func editProjectInfo(filename string) error {
 	return nil
 }
 
-// parseList splits a comma-separated string into a slice of trimmed strings.
-func parseList(input string) []string {
+// ProjectEdit lists the changes made by EditProjectInfo; nil fields are left as they are.
+type ProjectEdit struct {
+	Name        *string
+	Alias       *string
+	ProjectType *string
+	Notes       *[]string
+	Tags        *[]string
+}
+
+// EditProjectInfo applies edit to the project_info.toml of the project in
+// projectDir without prompting, and records the change in the database at dbPath.
+func EditProjectInfo(dbPath string, projectDir string, edit ProjectEdit) error {
+	filename := filepath.Join(projectDir, "project_info.toml")
+	proj, err := LoadProjectInfo(filename)
+	if err != nil {
+		return err
+	}
+
+	if edit.Name != nil {
+		if *edit.Name == "" {
+			return fmt.Errorf("the project name cannot be empty")
+		}
+		proj.Name = *edit.Name
+	}
+	if edit.Alias != nil {
+		proj.Alias = *edit.Alias
+	}
+	if edit.ProjectType != nil {
+		proj.ProjectType = *edit.ProjectType
+	}
+	if edit.Notes != nil {
+		proj.Notes = *edit.Notes
+	}
+	if edit.Tags != nil {
+		proj.Tags = *edit.Tags
+	}
+	proj.DateModified = time.Now()
+
+	if err := saveProjectInfo(filename, proj); err != nil {
+		return err
+	}
+	return RegisterProject(dbPath, projectDir, proj)
+}
+
+// ParseList splits a comma-separated string into a slice of trimmed strings.
+func ParseList(input string) []string {
 	parts := strings.Split(input, ",")
 	var list []string
 	for _, part := range parts {
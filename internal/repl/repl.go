@@ -1,24 +1,50 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
 
+	"github.com/johnjallday/flow-workspace/internal/config"
 	"github.com/johnjallday/flow-workspace/internal/project"
 	"github.com/johnjallday/flow-workspace/internal/root"
+	"github.com/johnjallday/flow-workspace/internal/shell"
 	"github.com/johnjallday/flow-workspace/internal/workspace"
 )
 
-// StartREPL detects the appropriate REPL to launch and allows clearing the screen with Ctrl+L.
-func StartREPL(dbPath string) {
-	reader := bufio.NewReader(os.Stdin)
+// Scope is the kind of directory a REPL is opened for.
+type Scope string
+
+// Scopes returned by DetectScope.
+const (
+	ScopeNone      Scope = ""
+	ScopeRoot      Scope = "root"      // has a .config folder
+	ScopeWorkspace Scope = "workspace" // has a ws_info.toml
+	ScopeProject   Scope = "project"   // has a project_info.toml
+)
+
+// DetectScope returns the scope of dir from the marker it contains.
+func DetectScope(dir string) Scope {
+	if info, err := os.Stat(filepath.Join(dir, ".config")); err == nil && info.IsDir() {
+		return ScopeRoot
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ws_info.toml")); err == nil {
+		return ScopeWorkspace
+	}
+	if _, err := os.Stat(filepath.Join(dir, "project_info.toml")); err == nil {
+		return ScopeProject
+	}
+	return ScopeNone
+}
+
+// StartREPL detects the appropriate REPL to launch.
+// Outside of any scope it offers to import a coding project, or opens the Root
+// REPL for the root_dir of the settings if nothing looks like one.
+func StartREPL(cfg *config.Config) {
+	sh := shell.New("REPL", "", "\n[repl] >> ")
 
 	for {
 		cwd, err := os.Getwd()
@@ -27,21 +53,18 @@ func StartREPL(dbPath string) {
 		}
 
 		// Detect REPL scope and launch the appropriate one.
-		if info, err := os.Stat(filepath.Join(cwd, ".config")); err == nil && info.IsDir() {
+		switch DetectScope(cwd) {
+		case ScopeRoot:
 			fmt.Println("Detected .config folder. Launching Root REPL.")
-			root.StartRootREPL(dbPath, cwd)
+			root.StartRootREPL(cfg, cwd)
 			return
-		}
-
-		if _, err := os.Stat(filepath.Join(cwd, "ws_info.toml")); err == nil {
+		case ScopeWorkspace:
 			fmt.Println("Detected ws_info.toml. Launching Workspace REPL.")
-			workspace.StartWorkspaceREPL(dbPath, cwd)
+			workspace.StartWorkspaceREPL(cfg, cwd)
 			return
-		}
-
-		if _, err := os.Stat(filepath.Join(cwd, "project_info.toml")); err == nil {
+		case ScopeProject:
 			fmt.Println("Detected project_info.toml. Launching Project REPL.")
-			project.StartProjectREPL(dbPath, cwd)
+			project.StartProjectREPL(cfg, cwd)
 			return
 		}
 
@@ -80,36 +103,32 @@ func StartREPL(dbPath string) {
 			for i, cand := range validCandidates {
 				fmt.Printf("  %d) %s\n", i+1, cand)
 			}
-			fmt.Print("This looks like a coding project. Would you like to import one of these directories? (Enter number, or press Enter to retry): ")
-			line, _ := reader.ReadString('\n')
-			line = strings.TrimSpace(line)
+			line, _ := sh.Ask("This looks like a coding project. Would you like to import one of these directories? (Enter number, or press Enter to retry): ")
 			if line != "" {
 				index, err := strconv.Atoi(line)
 				if err == nil && index >= 1 && index <= len(validCandidates) {
 					selected := validCandidates[index-1]
-					if err := project.ImportProject(selected); err != nil {
+					if err := project.ImportProject(cfg.DBPath, selected); err != nil {
 						fmt.Printf("Error importing project: %v\n", err)
 					} else {
 						fmt.Printf("Project imported successfully. Launching Project REPL for %s\n", selected)
-						project.StartProjectREPL(dbPath, selected)
+						project.StartProjectREPL(cfg, selected)
 						return
 					}
 				}
 			}
+		} else if cfg.RootDir != "" {
+			fmt.Printf("Unrecognized scope. Launching Root REPL for %s.\n", cfg.RootDir)
+			root.StartRootREPL(cfg, cfg.RootDir)
+			return
 		} else {
 			fmt.Println("Unrecognized scope for TODO REPL. Press 'Enter' to retry or 'Ctrl + L' to clear.")
-			fmt.Print("\n[repl] >> ")
-			line, err := reader.ReadString('\n')
+			// Ctrl+L is handled by the line editor of the shell.
+			line, err := sh.Ask(sh.Prompt())
 			if err != nil {
 				fmt.Println("Error reading input:", err)
 				return
 			}
-			line = strings.TrimSpace(line)
-			// Handle Ctrl+L (ASCII 12).
-			if line == "\x0c" {
-				clearScreen()
-				continue
-			}
 			if line != "" {
 				fmt.Println("Exiting REPL.")
 				return
@@ -153,20 +172,3 @@ func isCodingProject(dir string) bool {
 	}
 	return false
 }
-
-func clearScreen() {
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "linux":
-		cmd = exec.Command("clear") // Linux
-	case "windows":
-		cmd = exec.Command("cmd", "/c", "cls") // Windows
-	case "darwin":
-		cmd = exec.Command("clear") // macOS
-	default:
-		fmt.Println("CLS for", runtime.GOOS, "not implemented")
-		return
-	}
-	cmd.Stdout = os.Stdout
-	cmd.Run()
-}
@@ -1,133 +1,176 @@
 package root
 
 import (
-	"bufio"
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/johnjallday/flow-workspace/internal/config"
+	db "github.com/johnjallday/flow-workspace/internal/db/todo"
+	"github.com/johnjallday/flow-workspace/internal/search"
+	"github.com/johnjallday/flow-workspace/internal/shell"
+	"github.com/johnjallday/flow-workspace/internal/todo"
 	// Import the workspace REPL so we can call StartWorkspaceREPL
 	"github.com/johnjallday/flow-workspace/internal/workspace"
 )
 
 // StartRootREPL starts an interactive REPL at the root level.
-
-func StartRootREPL(dbPath string, rootDir string) {
+func StartRootREPL(cfg *config.Config, rootDir string) {
 	fmt.Println("Welcome to the ROOT-level REPL!")
 	fmt.Printf("Root Directory: %s\n", rootDir)
-	printRootHelp()
-
-	reader := bufio.NewReader(os.Stdin)
-
-	for {
-		fmt.Print("\n[ROOT] >> ")
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Println("Error reading input:", err)
-			return
-		}
-		line = strings.TrimSpace(line)
-
-		switch strings.ToLower(line) {
-		case "exit":
-			fmt.Println("Exiting Root REPL. Goodbye!")
-			return
-
-		case "help":
-			printRootHelp()
 
-		case "list":
-			// Lists all workspaces at the root level.
-			ListWorkspaces(rootDir)
-
-		case "projects":
-			// List subdirectories that contain a projects.toml.
-			ListProjects(rootDir)
-
-		case "select":
-			// Let the user select a workspace.
-			ListWorkspaces(rootDir)
-			selectedWorkspace := selectWorkspace(rootDir, reader)
-			if selectedWorkspace != "" {
-				workspace.StartWorkspaceREPL(dbPath, selectedWorkspace)
-			}
-
-		case "todo":
-			// New command: aggregate and print all todos from all workspaces.
-			ListAllTodos(rootDir)
-
-		default:
-			fmt.Println("Unknown command. Type 'help' for available commands.")
-		}
-	}
+	sh := shell.New("Root REPL", "root", "\n[ROOT] >> ")
+	sh.Register(
+		&shell.Command{
+			Name: "list",
+			Help: "List all workspaces in the root directory",
+			Run: func(c *shell.Context) error {
+				ListWorkspaces(cfg, rootDir)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "projects",
+			Help: "List subdirectories that contain 'projects.toml'",
+			Run: func(c *shell.Context) error {
+				ListProjects(cfg, rootDir)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name:  "find",
+			Args:  "[query]",
+			Help:  "Find projects by tag, type or age",
+			Usage: "The query is made of the terms tag:<tag> type:<type> stale:<days>.",
+			Run: func(c *shell.Context) error {
+				workspace.FindProjects(cfg, c.Rest, "")
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "select",
+			Args: "[workspace]",
+			Help: "Select a workspace by number or name (and load workspace REPL)",
+			Complete: func() []string {
+				names, _ := Workspaces(cfg, rootDir)
+				return names
+			},
+			Run: func(c *shell.Context) error {
+				if c.Rest == "" {
+					ListWorkspaces(cfg, rootDir)
+				}
+				if selected := selectWorkspace(c, cfg, rootDir); selected != "" {
+					workspace.StartWorkspaceREPL(cfg, selected)
+				}
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "todo",
+			Help: "Aggregate and list all TODOs from every workspace",
+			Run: func(c *shell.Context) error {
+				ListAllTodos(cfg, rootDir, "")
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "filter",
+			Args: "[filter]",
+			Help: "List TODOs from every workspace matching #label, @context, key:value or text",
+			Run: func(c *shell.Context) error {
+				filter, err := c.Arg("Enter filter (#label @context key:value text): ")
+				if err != nil {
+					return err
+				}
+				ListAllTodos(cfg, rootDir, filter)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "archive",
+			Help: "Browse archived TODOs of every workspace and restore one",
+			Run: func(c *shell.Context) error {
+				browseArchive(cfg, rootDir, c.Shell)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "search",
+			Args: "[words]",
+			Help: "Full-text search live and archived TODOs (word* for prefixes)",
+			Run: func(c *shell.Context) error {
+				query, err := c.Arg("Enter search words: ")
+				if err != nil {
+					return err
+				}
+				search.Run(cfg, rootDir, "", query)
+				return nil
+			},
+		},
+	)
+	sh.PrintHelp()
+	sh.Run()
 }
 
-// selectWorkspace lets the user pick from the listed workspace directories
-// and returns the selected directory's *absolute path* (or an empty string if canceled/invalid).
-func selectWorkspace(rootDir string, reader *bufio.Reader) string {
-	// Directories to skip
-	skip := map[string]bool{
-		".config":     true,
-		".spacedrive": true,
-		".DS_Store":   true,
-		".TagStudio":  true,
-	}
-
-	entries, err := os.ReadDir(rootDir)
+// selectWorkspace returns the *absolute path* of the workspace given by number
+// or name as the argument of c, asking for its number if there is none, or an
+// empty string if canceled/invalid.
+func selectWorkspace(c *shell.Context, cfg *config.Config, rootDir string) string {
+	dirs, err := Workspaces(cfg, rootDir)
 	if err != nil {
 		log.Printf("Failed to read root dir: %v\n", err)
 		return ""
 	}
-
-	var dirs []string
-	for _, e := range entries {
-		if e.IsDir() && !skip[e.Name()] {
-			dirs = append(dirs, e.Name())
-		}
-	}
 	if len(dirs) == 0 {
 		fmt.Println("No workspaces found (or all were skipped).")
 		return ""
 	}
 
+	input := c.Rest
 	for {
-		fmt.Print("Enter the number of the workspace to switch to (or 'cancel'): ")
-		input, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Println("Error reading input:", err)
-			return ""
+		if input == "" {
+			if input, err = c.Shell.Ask("Enter the number of the workspace to switch to (or 'cancel'): "); err != nil {
+				fmt.Println("Error reading input:", err)
+				return ""
+			}
 		}
-		input = strings.TrimSpace(input)
-
 		if strings.EqualFold(input, "cancel") {
 			return ""
 		}
 
-		idx, convErr := strconv.Atoi(input)
-		if convErr != nil || idx < 1 || idx > len(dirs) {
+		selected := ""
+		if idx, convErr := strconv.Atoi(input); convErr == nil && idx >= 1 && idx <= len(dirs) {
+			selected = dirs[idx-1]
+		}
+		for _, dir := range dirs {
+			if selected == "" && strings.EqualFold(dir, input) {
+				selected = dir
+			}
+		}
+		if selected == "" {
 			fmt.Println("Invalid selection. Try again.")
+			input = ""
 			continue
 		}
 
-		selected := dirs[idx-1]
 		selectedPath := filepath.Join(rootDir, selected)
 		fmt.Printf("Workspace selected: %s\n", selectedPath)
-
 		return selectedPath
 	}
 }
 
-// printRootHelp displays the available commands in the root-level REPL.
+// browseArchive lets the user search all archived todos and restore one into its project.
+func browseArchive(cfg *config.Config, rootDir string, sh *shell.Shell) {
+	conn, err := db.InitDB(cfg.DBPath)
+	if err != nil {
+		fmt.Println("Error connecting to db:", err)
+		return
+	}
+	defer conn.Close()
 
-func printRootHelp() {
-	fmt.Println(`Available commands (root-level):
-  help      - Show this help message
-  list      - List all workspaces in the root directory
-  projects  - List subdirectories that contain 'projects.toml'
-  select    - Select a workspace by number (and load workspace REPL)
-  todo      - Aggregate and list all TODOs from every workspace
-  exit      - Exit this Root REPL`)
+	todo.BrowseArchive(conn, sh, todo.ArchiveQuery{}, func(a todo.ArchivedTodo) (string, error) {
+		return workspace.ProjectDir(filepath.Join(rootDir, a.WorkspaceName), a.ProjectName)
+	})
 }
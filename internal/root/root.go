@@ -6,69 +6,83 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/johnjallday/flow-workspace/internal/config"
+	"github.com/johnjallday/flow-workspace/internal/output"
 	// Import the workspace package to load and list projects
 	"github.com/johnjallday/flow-workspace/internal/todo"
 	"github.com/johnjallday/flow-workspace/internal/workspace"
 )
 
+// Workspaces returns the names of the sub-directories of rootDir except for
+// the ones in the settings' skip_dirs.
+func Workspaces(cfg *config.Config, rootDir string) ([]string, error) {
+	entries, err := os.ReadDir(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && !cfg.Skipped(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
 // ListWorkspaces reads the root directory and prints out
 // all sub-directories except for certain hidden/system ones.
-func ListWorkspaces(rootDir string) {
-	entries, err := os.ReadDir(rootDir)
+func ListWorkspaces(cfg *config.Config, rootDir string) {
+	names, err := Workspaces(cfg, rootDir)
 	if err != nil {
 		log.Printf("Failed to read root dir '%s': %v\n", rootDir, err)
 		return
 	}
 
-	// Directories to skip
-	skip := map[string]bool{
-		".config":     true,
-		".spacedrive": true,
-		".DS_Store":   true,
-		".TagStudio":  true,
-	}
-
 	fmt.Printf("\nAvailable Workspaces in %s:\n", rootDir)
-	count := 0
-
-	for _, e := range entries {
-		name := e.Name()
-		if skip[name] {
-			continue
-		}
-		if e.IsDir() {
-			count++
-			fmt.Printf("%d) %s\n", count, name)
-		}
+	for i, name := range names {
+		fmt.Printf("%d) %s\n", i+1, name)
 	}
 
-	if count == 0 {
+	if len(names) == 0 {
 		fmt.Println("No workspaces found (or all were skipped).")
 	}
 }
 
+// WorkspaceListing returns the workspaces under rootDir as a listing for
+// output.Write. The number of projects is nil for a workspace without a
+// readable projects.toml.
+func WorkspaceListing(cfg *config.Config, rootDir string) (*output.Listing, error) {
+	names, err := Workspaces(cfg, rootDir)
+	if err != nil {
+		return nil, err
+	}
+	l := output.NewListing("name", "path", "projects")
+	for _, name := range names {
+		path := filepath.Join(rootDir, name)
+		var count interface{}
+		if projs, err := workspace.LoadProjectsToml(path); err == nil {
+			count = len(projs.Projects)
+		}
+		l.Add(name, path, count)
+	}
+	return l, nil
+}
+
 // ListProjects looks for a `projects.toml` file in each subdirectory of rootDir
 // (one level only). If found, it loads and prints the contained projects.
-func ListProjects(rootDir string) {
+func ListProjects(cfg *config.Config, rootDir string) {
 	entries, err := os.ReadDir(rootDir)
 	if err != nil {
 		log.Printf("Failed to read root dir '%s': %v\n", rootDir, err)
 		return
 	}
 
-	skip := map[string]bool{
-		".config":     true,
-		".spacedrive": true,
-		".DS_Store":   true,
-		".TagStudio":  true,
-	}
-
 	fmt.Printf("\nScanning for 'projects.toml' in subfolders of: %s\n", rootDir)
 	foundAny := false
 
 	for _, e := range entries {
 		name := e.Name()
-		if skip[name] {
+		if cfg.Skipped(name) {
 			continue
 		}
 		if e.IsDir() {
@@ -96,86 +110,42 @@ func ListProjects(rootDir string) {
 	}
 }
 
-// ListAllTodos aggregates and prints all TODOs from every workspace found under rootDir.
-func ListAllTodos(rootDir string) {
-	// Directories to skip at the root level.
-	skip := map[string]bool{
-		".config":     true,
-		".spacedrive": true,
-		".DS_Store":   true,
-		".TagStudio":  true,
-	}
+// CollectTodos aggregates the TODOs from every workspace found under rootDir.
+func CollectTodos(cfg *config.Config, rootDir string) []todo.Todo {
 
 	entries, err := os.ReadDir(rootDir)
 	if err != nil {
 		log.Printf("Failed to read root directory '%s': %v\n", rootDir, err)
-		return
+		return nil
 	}
 
 	var aggregatedTodos []todo.Todo
 
 	// Loop through each subdirectory (workspace) in the root directory.
 	for _, e := range entries {
-		if !e.IsDir() || skip[e.Name()] {
+		if !e.IsDir() || cfg.Skipped(e.Name()) {
 			continue
 		}
 
 		workspacePath := filepath.Join(rootDir, e.Name())
-		projectsTomlPath := filepath.Join(workspacePath, "projects.toml")
-		if _, err := os.Stat(projectsTomlPath); os.IsNotExist(err) {
-			// Skip this workspace if no projects.toml exists.
-			fmt.Printf("Skipping workspace '%s': no projects.toml found.\n", workspacePath)
-			continue
-		}
-
-		// Load the projects from the workspace’s projects.toml.
-		projs, err := workspace.LoadProjectsToml(workspacePath)
+		tasks, err := workspace.CollectTodos(cfg, workspacePath)
 		if err != nil {
-			fmt.Printf("Skipping workspace '%s': error loading projects.toml: %v\n", workspacePath, err)
+			log.Printf("Skipping workspace '%s': %v\n", workspacePath, err)
 			continue
 		}
+		aggregatedTodos = append(aggregatedTodos, tasks...)
+	}
 
-		// For each project, decide which folder to check for a todo.md.
-		for _, proj := range projs.Projects {
-			var projectDir string
-
-			// Use the project Path if it’s provided (and not just the default "./")
-			if proj.Path != "" && proj.Path != "./" {
-				if filepath.IsAbs(proj.Path) {
-					projectDir = filepath.Clean(proj.Path)
-				} else {
-					projectDir = filepath.Join(workspacePath, proj.Path)
-				}
-			} else {
-				// Default: assume the project is in a folder named after the project.
-				projectDir = filepath.Join(workspacePath, proj.Name)
-			}
-
-			todoFile := filepath.Join(projectDir, "todo.md")
-			if _, err := os.Stat(todoFile); os.IsNotExist(err) {
-				// Skip if todo.md does not exist.
-				continue
-			}
-
-			// Load the tasks from the todo.md file.
-			tasks, err := todo.LoadAllTodos(todoFile)
-			if err != nil {
-				fmt.Printf("Error loading todos from '%s': %v\n", todoFile, err)
-				continue
-			}
-
-			// Optionally, annotate each task with the project and workspace names.
-			for i := range tasks {
-				if tasks[i].ProjectName == "" {
-					tasks[i].ProjectName = proj.Name
-				}
-				if tasks[i].WorkspaceName == "" {
-					tasks[i].WorkspaceName = e.Name() // using the workspace folder name
-				}
-			}
+	return aggregatedTodos
+}
 
-			aggregatedTodos = append(aggregatedTodos, tasks...)
-		}
+// ListAllTodos aggregates and prints all TODOs from every workspace found under rootDir,
+// sorted by priority.
+// If filter is not empty, only matching todos are shown (see todo.FilterTodos).
+func ListAllTodos(cfg *config.Config, rootDir string, filter string) {
+	aggregatedTodos := CollectTodos(cfg, rootDir)
+	if filter != "" {
+		aggregatedTodos = todo.FilterTodos(aggregatedTodos, filter)
 	}
 
 	// Print the aggregated list.
@@ -184,6 +154,9 @@ func ListAllTodos(rootDir string) {
 		return
 	}
 
+	// Show the most urgent tasks first.
+	todo.SortByPriority(aggregatedTodos)
+
 	fmt.Println("\nAggregated TODOs across all Workspaces:")
 
 	todo.PrintTodos(aggregatedTodos)
@@ -0,0 +1,167 @@
+package search
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/johnjallday/flow-workspace/internal/config"
+	"github.com/johnjallday/flow-workspace/internal/db/registry"
+	db "github.com/johnjallday/flow-workspace/internal/db/todo"
+	"github.com/johnjallday/flow-workspace/internal/todo"
+	"github.com/olekukonko/tablewriter"
+)
+
+// MaxResults is the number of results shown by Run.
+const MaxResults = 20
+
+// Collect gathers the tasks to search: every todo.md below dir, and the live
+// and archived tasks stored in the database of cfg, limited to workspace if it
+// is not empty and otherwise to the workspaces in dir (see inDir). The
+// directories in cfg.SkipDirs are not searched. Files are only read, never
+// rewritten. A task found both in a file and in the database (e.g. a synced
+// project) is only returned once.
+func Collect(cfg *config.Config, dir string, workspace string) ([]todo.Todo, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	var todos []todo.Todo
+	seen := make(map[string]bool)
+	key := func(t todo.Todo) string {
+		return strings.ToLower(t.WorkspaceName + "/" + t.ProjectName + "/" + t.ID)
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Skip files that can't be accessed.
+			return nil
+		}
+		if info.IsDir() {
+			if cfg.Skipped(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != "todo.md" {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		projectDir := filepath.Dir(path)
+		for _, t := range todo.ParseDocument(string(content)).Todos() {
+			if t.ProjectName == "" {
+				t.ProjectName = filepath.Base(projectDir)
+			}
+			if t.WorkspaceName == "" {
+				t.WorkspaceName = filepath.Base(filepath.Dir(projectDir))
+			}
+			seen[key(t)] = true
+			todos = append(todos, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning '%s': %w", dir, err)
+	}
+
+	conn, err := db.InitDB(cfg.DBPath)
+	if err != nil {
+		return todos, fmt.Errorf("error connecting to db: %w", err)
+	}
+	defer conn.Close()
+	stored, err := todo.LoadDatabaseTodos(conn, workspace)
+	if err != nil {
+		return todos, err
+	}
+	var paths map[string]string
+	if workspace == "" {
+		if paths, err = registry.WorkspacePaths(conn); err != nil {
+			return todos, err
+		}
+	}
+	for _, t := range stored {
+		if t.ID != "" && !t.Archived && seen[key(t)] {
+			continue
+		}
+		// The database holds the tasks of every root; only search the ones of this root.
+		if workspace == "" && !inDir(paths, t.WorkspaceName, dir) {
+			continue
+		}
+		todos = append(todos, t)
+	}
+	return todos, nil
+}
+
+// inDir reports whether the workspace called name is in dir: its registered
+// directory (see registry.WorkspacePaths) is dir or below it, or, for a
+// workspace that was never registered, dir has a directory of that name.
+func inDir(paths map[string]string, name string, dir string) bool {
+	if name == "" {
+		return false
+	}
+	if path, ok := paths[strings.ToLower(name)]; ok {
+		rel, err := filepath.Rel(dir, path)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	info, err := os.Stat(filepath.Join(dir, name))
+	return err == nil && info.IsDir()
+}
+
+// Run searches the tasks below dir and in the database (see Collect) for query
+// and prints the best matches.
+func Run(cfg *config.Config, dir string, workspace string, query string) {
+	todos, err := Collect(cfg, dir, workspace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error collecting tasks:", err)
+		if len(todos) == 0 {
+			return
+		}
+	}
+	PrintResults(NewIndex(todos).Search(query, MaxResults))
+}
+
+// PrintResults prints search results in a table, best match first.
+func PrintResults(results []Result) {
+	if len(results) == 0 {
+		fmt.Println("No matching TODOs found.")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.SetHeader([]string{"No.", "Score", "Status", "Description", "Completed", "Project", "Workspace"})
+
+	archivedStyle := color.New(color.FgHiBlack)
+	doneStyle := color.New(color.FgGreen)
+	openStyle := color.New(color.FgRed)
+	for i, r := range results {
+		t := r.Todo
+		var status string
+		switch {
+		case t.Archived:
+			status = archivedStyle.Sprint("archived")
+		case !t.CompletedDate.IsZero():
+			status = doneStyle.Sprint("done")
+		case t.Ongoing:
+			status = "ongoing"
+		default:
+			status = openStyle.Sprint("open")
+		}
+		completed := ""
+		if !t.CompletedDate.IsZero() {
+			completed = t.CompletedDate.Format("2006-01-02")
+		}
+		table.Append([]string{strconv.Itoa(i + 1), fmt.Sprintf("%.2f", r.Score), status, t.Description,
+			completed, t.ProjectName, t.WorkspaceName})
+	}
+	table.Render()
+}
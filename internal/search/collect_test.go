@@ -0,0 +1,90 @@
+package search
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/config"
+	"github.com/johnjallday/flow-workspace/internal/db/migrate"
+	"github.com/johnjallday/flow-workspace/internal/db/registry"
+	"github.com/johnjallday/flow-workspace/internal/todo"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// writeTodoFile writes a todo.md with a single task described as description
+// into the project dir.
+func writeTodoFile(t *testing.T, dir string, description string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "# todo\n\n- [ ] " + description + " #created:2026-01-01\n"
+	if err := os.WriteFile(filepath.Join(dir, "todo.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectOnlyStoredTasksOfRoot(t *testing.T) {
+	base := t.TempDir()
+	rootA, rootB := filepath.Join(base, "a"), filepath.Join(base, "b")
+	writeTodoFile(t, filepath.Join(rootA, "ws1", "proj"), "file in a")
+	writeTodoFile(t, filepath.Join(rootB, "ws2", "proj"), "file in b")
+	// ws3 was never registered but is a workspace of root a.
+	if err := os.MkdirAll(filepath.Join(rootA, "ws3"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.DBPath = filepath.Join(base, "flow.sqlite")
+	conn, err := sql.Open("sqlite3", cfg.DBPath+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := migrate.Apply(conn); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{filepath.Join(rootA, "ws1"), filepath.Join(rootB, "ws2")} {
+		if err := registry.SaveWorkspace(conn, dir, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, workspace := range []string{"ws1", "ws2", "ws3", "ws4"} {
+		stored := todo.Todo{Description: "archived in " + workspace, CreatedDate: time.Now(),
+			CompletedDate: time.Now(), ProjectName: "proj", WorkspaceName: workspace}
+		if err := todo.InsertTodo(conn, stored); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		dir       string
+		workspace string
+		want      []string
+	}{
+		{name: "root a", dir: rootA, want: []string{"archived in ws1", "archived in ws3", "file in a"}},
+		{name: "root b", dir: rootB, want: []string{"archived in ws2", "file in b"}},
+		{name: "both roots", dir: base, want: []string{"archived in ws1", "archived in ws2", "file in a", "file in b"}},
+		{name: "workspace", dir: filepath.Join(rootB, "ws2"), workspace: "ws2", want: []string{"archived in ws2", "file in b"}},
+	}
+	for _, tt := range tests {
+		todos, err := Collect(cfg, tt.dir, tt.workspace)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, td := range todos {
+			got = append(got, td.Description)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Collect = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/johnjallday/flow-workspace/internal/todo"
+)
+
+// Field weights: a match in the description counts more than one in the tags,
+// which counts more than one in the notes.
+const (
+	descriptionWeight = 3.0
+	tagWeight         = 2.0
+	noteWeight        = 1.0
+)
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Result is a task matching a search together with its relevance score.
+type Result struct {
+	Todo  todo.Todo
+	Score float64
+}
+
+// Index is an in-memory full-text index over the description, tags and notes
+// of a set of tasks.
+type Index struct {
+	todos    []todo.Todo
+	terms    []map[string]float64 // weighted term frequencies per task
+	lengths  []float64            // weighted number of terms per task
+	postings map[string][]int     // tasks containing each term
+	avgLen   float64
+}
+
+// NewIndex builds an index over todos.
+func NewIndex(todos []todo.Todo) *Index {
+	idx := &Index{todos: todos, postings: make(map[string][]int)}
+	var total float64
+	for i, t := range todos {
+		freq := make(map[string]float64)
+		var length float64
+		add := func(text string, weight float64) {
+			for _, term := range tokenize(text) {
+				freq[term] += weight
+				length += weight
+			}
+		}
+		add(t.Description, descriptionWeight)
+		add(todo.FormatTags(t), tagWeight)
+		add(strings.Join(t.Notes, " "), noteWeight)
+
+		for term := range freq {
+			idx.postings[term] = append(idx.postings[term], i)
+		}
+		idx.terms = append(idx.terms, freq)
+		idx.lengths = append(idx.lengths, length)
+		total += length
+	}
+	if len(todos) > 0 {
+		idx.avgLen = total / float64(len(todos))
+	}
+	return idx
+}
+
+// Search returns the tasks matching every word of query, best match first.
+// A word ending in "*" matches every term starting with it, so "migrat*"
+// finds "migration" and "migrated". At most limit results are returned
+// if limit is positive.
+func (idx *Index) Search(query string, limit int) []Result {
+	var words []string
+	for _, field := range strings.Fields(query) {
+		prefix := strings.HasSuffix(field, "*")
+		for _, term := range tokenize(field) {
+			if prefix {
+				term += "*"
+			}
+			words = append(words, term)
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	scores := make(map[int]float64)
+	for n, word := range words {
+		matched := make(map[int]float64)
+		for _, term := range idx.expand(word) {
+			docs := idx.postings[term]
+			idf := math.Log(1 + (float64(len(idx.todos))-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+			for _, i := range docs {
+				tf := idx.terms[i][term]
+				norm := tf + k1*(1-b+b*idx.lengths[i]/idx.avgLen)
+				matched[i] += idf * tf * (k1 + 1) / norm
+			}
+		}
+		// Every word must match: keep only tasks matched by all words so far.
+		if n == 0 {
+			scores = matched
+			continue
+		}
+		for i := range scores {
+			if s, ok := matched[i]; ok {
+				scores[i] += s
+			} else {
+				delete(scores, i)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for i, score := range scores {
+		results = append(results, Result{Todo: idx.todos[i], Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		// Prefer the more recently finished task among equally good matches.
+		return results[i].Todo.CompletedDate.After(results[j].Todo.CompletedDate)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// expand returns the indexed terms a query word stands for.
+func (idx *Index) expand(word string) []string {
+	prefix, ok := strings.CutSuffix(word, "*")
+	if !ok {
+		return []string{word}
+	}
+	var terms []string
+	for term := range idx.postings {
+		if strings.HasPrefix(term, prefix) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// tokenize splits text into lower-case words of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
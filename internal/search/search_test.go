@@ -0,0 +1,80 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/todo"
+)
+
+func TestSearch(t *testing.T) {
+	todos := []todo.Todo{
+		{ID: "desc", Description: "Fix the migration script"},
+		{ID: "tag", Description: "Write release notes", Tags: []todo.Tag{{Key: "migration"}}},
+		{ID: "note", Description: "Review pull request", Notes: []string{"mentions the migration"}},
+		{ID: "other", Description: "Buy milk"},
+		{ID: "both", Description: "Migrated database backup", Notes: []string{"backup the database first"}},
+	}
+	idx := NewIndex(todos)
+
+	tests := []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		// A match in the description ranks above one in the tags, which ranks above one in the notes.
+		{query: "migration", want: []string{"desc", "tag", "note"}},
+		{query: "MIGRATION", want: []string{"desc", "tag", "note"}},
+		// "migrated" is rarer than "migration", so it weighs more.
+		{query: "migrat*", want: []string{"both", "desc", "tag", "note"}},
+		{query: "migration", limit: 2, want: []string{"desc", "tag"}},
+		// Every word has to match.
+		{query: "migration script", want: []string{"desc"}},
+		{query: "database backup", want: []string{"both"}},
+		{query: "milk migration", want: nil},
+		{query: "nothing", want: nil},
+		{query: "", want: nil},
+		{query: "  ,. ", want: nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range idx.Search(tt.query, tt.limit) {
+			got = append(got, r.Todo.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q, %d) = %v, want %v", tt.query, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPrefersRecentlyCompleted(t *testing.T) {
+	older := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	idx := NewIndex([]todo.Todo{
+		{ID: "old", Description: "deploy", CompletedDate: older},
+		{ID: "new", Description: "deploy", CompletedDate: newer},
+	})
+
+	results := idx.Search("deploy", 0)
+	if len(results) != 2 || results[0].Todo.ID != "new" || results[1].Todo.ID != "old" {
+		t.Fatalf("Search(deploy) = %v, want new before old", results)
+	}
+	if results[0].Score != results[1].Score || results[0].Score <= 0 {
+		t.Errorf("equal tasks scored %v and %v, want the same positive score", results[0].Score, results[1].Score)
+	}
+}
+
+func TestSearchEmptyIndex(t *testing.T) {
+	if results := NewIndex(nil).Search("anything", 0); len(results) != 0 {
+		t.Errorf("Search on an empty index = %v, want none", results)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("Fix #p:1 the Über-bug, v2!")
+	want := []string{"fix", "p", "1", "the", "über", "bug", "v2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenize = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,163 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/johnjallday/flow-workspace/internal/startup"
+)
+
+// ErrInterrupt is returned when a prompt is interrupted with Ctrl+C.
+var ErrInterrupt = errors.New("interrupted")
+
+// Lines are read with the line editor of the Shell when standard input is a
+// terminal, and from stdin otherwise, e.g. when commands are piped in. The
+// editor only reads while a line is being edited, and stdin is shared so that
+// a REPL opened from another one reads the input the first one has already
+// buffered.
+var stdin = bufio.NewReader(os.Stdin)
+
+// lineEditor returns the line editor of the REPL, or nil if standard input is
+// not a terminal or the editor can't be set up.
+func (s *Shell) lineEditor() *readline.Instance {
+	if s.editorTried {
+		return s.editor
+	}
+	s.editorTried = true
+	if !readline.DefaultIsTerminal() {
+		return nil
+	}
+	rl, err := readline.NewEx(&readline.Config{
+		HistoryFile:            s.historyPath(),
+		HistorySearchFold:      true,
+		DisableAutoSaveHistory: true,
+		AutoComplete:           completer{s},
+	})
+	if err != nil {
+		return nil
+	}
+	s.editor = rl
+	return s.editor
+}
+
+// closeEditor releases the line editor of the REPL, if any.
+func (s *Shell) closeEditor() {
+	if s.editor != nil {
+		s.editor.Close()
+	}
+	s.editor, s.editorTried = nil, false
+}
+
+// readLine shows prompt and returns the line entered, trimmed. A command line
+// is completed and saved to the history of the REPL; answers to the prompts
+// of a command are neither.
+func (s *Shell) readLine(prompt string, command bool) (string, error) {
+	rl := s.lineEditor()
+	if rl == nil {
+		fmt.Print(prompt)
+		line, err := stdin.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+
+	// The editor redraws its line with the prompt, so only the last line of
+	// the prompt can be given to it.
+	if i := strings.LastIndex(prompt, "\n"); i >= 0 {
+		fmt.Print(prompt[:i+1])
+		prompt = prompt[i+1:]
+	}
+	rl.SetPrompt(prompt)
+	s.completing = command
+	line, err := rl.Readline()
+	s.completing = false
+	if errors.Is(err, readline.ErrInterrupt) {
+		return "", ErrInterrupt
+	}
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+	if command && line != "" {
+		rl.SaveHistory(line)
+	}
+	return line, nil
+}
+
+// historyPath returns the file of the history of the REPL in the history
+// directory of the data directory. The history is only kept in memory if it
+// has no name or the directory can't be created.
+func (s *Shell) historyPath() string {
+	if s.History == "" {
+		return ""
+	}
+	dataDir, err := startup.DataDir()
+	if err != nil {
+		return ""
+	}
+	dir := filepath.Join(dataDir, "history")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ""
+	}
+	return filepath.Join(dir, s.History)
+}
+
+// completer completes the names of the commands of a Shell and, once a command
+// is typed, its arguments.
+type completer struct {
+	s *Shell
+}
+
+// Do returns the endings of the candidates for the word before pos, and the
+// length of that word.
+func (c completer) Do(line []rune, pos int) ([][]rune, int) {
+	if !c.s.completing {
+		return nil, 0
+	}
+	text := strings.TrimLeft(string(line[:pos]), " \t")
+	var candidates []string
+	word := text
+
+	words := strings.Fields(text)
+	cmd, n, exact := c.s.lookup(words)
+	if cmd != nil && exact && (len(words) > n || strings.HasSuffix(text, " ")) {
+		if cmd.Complete == nil {
+			return nil, 0
+		}
+		word = ""
+		if !strings.HasSuffix(text, " ") {
+			word = words[len(words)-1]
+		}
+		for _, arg := range cmd.Complete() {
+			if hasPrefixFold(arg, word) {
+				candidates = append(candidates, arg)
+			}
+		}
+	} else {
+		for _, cmd := range c.s.Commands() {
+			for _, name := range cmd.names() {
+				if hasPrefixFold(name, text) {
+					candidates = append(candidates, name)
+				}
+			}
+		}
+	}
+
+	var endings [][]rune
+	for _, candidate := range candidates {
+		endings = append(endings, []rune(string([]rune(candidate)[len([]rune(word)):])+" "))
+	}
+	return endings, len([]rune(word))
+}
+
+// hasPrefixFold reports whether s starts with prefix, ignoring case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,370 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/chzyer/readline"
+)
+
+// ErrExit is returned by a command to leave the REPL, e.g. after handing
+// over to another one.
+var ErrExit = errors.New("exit")
+
+// Command is a command of a REPL.
+type Command struct {
+	Name    string   // may be several words, e.g. "list projects"
+	Aliases []string // other names the command can be called by
+	Args    string   // usage of the arguments, e.g. "[task]"
+	Help    string   // one-line description shown by "help"
+	Usage   string   // optional details shown by "help <command>"
+	Exact   bool     // must be typed in full, for commands that destroy data
+	Run     func(c *Context) error
+
+	// Complete returns the candidates for completing an argument, if any.
+	Complete func() []string
+}
+
+// names returns the name and the aliases of the command.
+func (cmd *Command) names() []string {
+	return append([]string{cmd.Name}, cmd.Aliases...)
+}
+
+// Context is passed to a command being run.
+type Context struct {
+	Shell *Shell
+	Args  []string // the words after the command name
+	Rest  string   // the line after the command name, trimmed
+}
+
+// Arg returns the arguments of the command, or asks for them with prompt if
+// none were given, so "complete 3" works as well as "complete" followed by
+// the prompt.
+func (c *Context) Arg(prompt string) (string, error) {
+	if c.Rest != "" {
+		return c.Rest, nil
+	}
+	return c.Shell.Ask(prompt)
+}
+
+// Shell reads commands and runs the registered command they name. Every REPL
+// has the built-in commands "help [command]" and "exit".
+type Shell struct {
+	Title  string        // e.g. "Project REPL", used in the help and the exit message
+	Prompt func() string // returns the prompt shown before each command
+	Before func()        // runs before each prompt, e.g. to redraw the screen
+	Pause  bool          // wait for Enter after each command, for REPLs that redraw in Before
+
+	// History names the file the commands are saved to, per scope, so they
+	// can be recalled in later sessions; empty keeps them for the session only.
+	History string
+
+	commands    []*Command
+	help        *Command
+	exit        *Command
+	editor      *readline.Instance
+	editorTried bool
+	completing  bool // whether a command is being read, not an answer
+}
+
+// New returns a Shell reading from standard input with only the built-in
+// commands, showing prompt before each command and saving the commands to the
+// history file named history.
+func New(title string, history string, prompt string) *Shell {
+	s := &Shell{
+		Title:   title,
+		Prompt:  func() string { return prompt },
+		History: history,
+	}
+	s.help = &Command{
+		Name: "help",
+		Args: "[command]",
+		Help: "Show this help message, or the help of a command",
+		Run:  s.runHelp,
+		Usage: `Commands may be abbreviated by any unambiguous prefix of their name,
+except those that delete something. On a terminal, Tab completes commands and
+their arguments, the arrow keys recall earlier commands, Ctrl+R searches them
+and Ctrl+L clears the screen.`,
+		Complete: func() []string {
+			var names []string
+			for _, cmd := range s.Commands() {
+				names = append(names, cmd.Name)
+			}
+			return names
+		},
+	}
+	s.exit = &Command{
+		Name:    "exit",
+		Aliases: []string{"quit"},
+		Help:    "Exit the " + title,
+		Run: func(c *Context) error {
+			fmt.Printf("Exiting %s. Goodbye!\n", title)
+			return ErrExit
+		},
+	}
+	return s
+}
+
+// Register adds commands to the REPL. They are listed by "help" in the order
+// they are registered.
+func (s *Shell) Register(cmds ...*Command) {
+	s.commands = append(s.commands, cmds...)
+}
+
+// Commands returns every command of the REPL, the built-in ones included.
+func (s *Shell) Commands() []*Command {
+	cmds := append([]*Command{s.help}, s.commands...)
+	return append(cmds, s.exit)
+}
+
+// Run reads and runs commands until one of them exits the REPL or the input ends.
+func (s *Shell) Run() {
+	defer s.closeEditor()
+	for {
+		if s.Before != nil {
+			s.Before()
+		}
+		line, err := s.readLine(s.Prompt(), true)
+		if errors.Is(err, ErrInterrupt) {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Println("Error reading input:", err)
+			}
+			fmt.Println()
+			return
+		}
+		if s.Exec(line) {
+			return
+		}
+		if s.Pause && strings.TrimSpace(line) != "" {
+			if _, err := s.Ask("Press Enter to continue..."); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Exec runs the command named by line and reports whether it exits the REPL.
+func (s *Shell) Exec(line string) bool {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return false
+	}
+	cmd, n := s.Lookup(words)
+	if cmd == nil {
+		s.unknown(words[0])
+		return false
+	}
+
+	c := &Context{Shell: s, Args: words[n:], Rest: afterWords(line, n)}
+	err := cmd.Run(c)
+	switch {
+	case errors.Is(err, ErrExit):
+		return true
+	case errors.Is(err, ErrInterrupt):
+		fmt.Println("Canceled.")
+	case err != nil:
+		fmt.Println("Error:", err)
+	}
+	return false
+}
+
+// Lookup returns the command named by the first words of a line and the number
+// of words its name takes up. A name made of more words wins over a shorter
+// one, an exact name over a prefix; a prefix only matches if it is unambiguous.
+func (s *Shell) Lookup(words []string) (*Command, int) {
+	cmd, n, _ := s.lookup(words)
+	return cmd, n
+}
+
+// lookup is Lookup, also reporting whether the command was named in full.
+func (s *Shell) lookup(words []string) (*Command, int, bool) {
+	if len(words) == 0 {
+		return nil, 0, false
+	}
+	var best *Command
+	bestWords := 0
+	for _, cmd := range s.Commands() {
+		for _, name := range cmd.names() {
+			nameWords := strings.Fields(name)
+			if len(nameWords) > bestWords && matchWords(words, nameWords) {
+				best, bestWords = cmd, len(nameWords)
+			}
+		}
+	}
+	if best != nil {
+		return best, bestWords, true
+	}
+
+	// Fall back to a unique command starting with the first word. Commands
+	// that must be typed in full still count, so "de" is ambiguous rather than
+	// running "describe" when there is also a "delete".
+	var prefixed []*Command
+	for _, cmd := range s.Commands() {
+		for _, name := range cmd.names() {
+			if strings.HasPrefix(strings.ToLower(name), strings.ToLower(words[0])) {
+				prefixed = append(prefixed, cmd)
+				break
+			}
+		}
+	}
+	if len(prefixed) == 1 && !prefixed[0].Exact {
+		return prefixed[0], 1, false
+	}
+	return nil, 0, false
+}
+
+// matchWords reports whether words starts with the words of a command name.
+func matchWords(words []string, nameWords []string) bool {
+	if len(words) < len(nameWords) {
+		return false
+	}
+	for i, w := range nameWords {
+		if !strings.EqualFold(words[i], w) {
+			return false
+		}
+	}
+	return true
+}
+
+// afterWords returns line without its first n words, trimmed.
+func afterWords(line string, n int) string {
+	rest := strings.TrimSpace(line)
+	for i := 0; i < n; i++ {
+		if end := strings.IndexAny(rest, " \t"); end >= 0 {
+			rest = strings.TrimSpace(rest[end:])
+		} else {
+			rest = ""
+		}
+	}
+	return rest
+}
+
+// unknown reports an unknown command, suggesting the closest command names.
+func (s *Shell) unknown(word string) {
+	if suggestions := s.suggest(word); len(suggestions) > 0 {
+		fmt.Printf("Unknown command '%s'. Did you mean %s?\n", word, strings.Join(suggestions, " or "))
+		return
+	}
+	fmt.Printf("Unknown command '%s'. Type 'help' for available commands.\n", word)
+}
+
+// suggest returns the command names within a small edit distance of word, and
+// those it is a prefix of, such as commands that must be typed in full.
+func (s *Shell) suggest(word string) []string {
+	word = strings.ToLower(word)
+	maxDistance := 1 + len(word)/4
+	var suggestions []string
+	seen := make(map[string]bool)
+	for _, cmd := range s.Commands() {
+		for _, name := range cmd.names() {
+			first := strings.ToLower(strings.Fields(name)[0])
+			close := distance(word, first) <= maxDistance || distance(word, strings.ToLower(name)) <= maxDistance
+			if !seen[name] && (close || strings.HasPrefix(strings.ToLower(name), word)) {
+				seen[name] = true
+				suggestions = append(suggestions, "'"+name+"'")
+			}
+		}
+	}
+	sort.Strings(suggestions)
+	return suggestions
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// PrintHelp lists the commands of the REPL.
+func (s *Shell) PrintHelp() {
+	cmds := s.Commands()
+	width := 0
+	for _, cmd := range cmds {
+		width = max(width, len(usage(cmd)))
+	}
+	fmt.Printf("Available commands (%s):\n", s.Title)
+	for _, cmd := range cmds {
+		fmt.Printf("  %-*s - %s\n", width, usage(cmd), cmd.Help)
+	}
+}
+
+// usage returns the name of a command followed by its arguments.
+func usage(cmd *Command) string {
+	return strings.TrimSpace(cmd.Name + " " + cmd.Args)
+}
+
+// runHelp is the built-in "help" command.
+func (s *Shell) runHelp(c *Context) error {
+	if len(c.Args) == 0 {
+		s.PrintHelp()
+		return nil
+	}
+	cmd, _ := s.Lookup(c.Args)
+	if cmd == nil {
+		s.unknown(c.Args[0])
+		return nil
+	}
+	fmt.Printf("%s - %s\n", usage(cmd), cmd.Help)
+	if len(cmd.Aliases) > 0 {
+		fmt.Println("Aliases:", strings.Join(cmd.Aliases, ", "))
+	}
+	if cmd.Usage != "" {
+		fmt.Println(cmd.Usage)
+	}
+	return nil
+}
+
+// Ask shows prompt and returns the line entered, trimmed.
+func (s *Shell) Ask(prompt string) (string, error) {
+	return s.readLine(prompt, false)
+}
+
+// Confirm asks a yes/no question and reports whether it was answered yes.
+func (s *Shell) Confirm(question string) bool {
+	answer, err := s.Ask(question + " (y/n): ")
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
+
+// ClearScreen clears the terminal.
+func ClearScreen() {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "darwin":
+		cmd = exec.Command("clear")
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "cls")
+	default:
+		fmt.Print("\033[H\033[2J")
+		return
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Run()
+}
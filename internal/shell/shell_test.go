@@ -0,0 +1,117 @@
+package shell
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testShell() *Shell {
+	s := New("Test REPL", "", "> ")
+	for _, cmd := range []*Command{
+		{Name: "list projects", Aliases: []string{"projects"}},
+		{Name: "list"},
+		{Name: "select project", Aliases: []string{"select"}},
+		{Name: "complete", Aliases: []string{"done"}},
+		{Name: "delete", Aliases: []string{"rm"}, Exact: true},
+		{Name: "weekly"},
+	} {
+		s.Register(cmd)
+	}
+	return s
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		line  string
+		want  string // name of the command, "" for none
+		words int
+	}{
+		{"list", "list", 1},
+		{"list projects", "list projects", 2},
+		{"LIST Projects extra", "list projects", 2},
+		{"projects", "list projects", 1},
+		{"select 3", "select project", 1},
+		{"select project 3", "select project", 2},
+		{"done 3", "complete", 1},
+		{"comp 3", "complete", 1},
+		{"w", "weekly", 1},
+		{"delete 3", "delete", 1},
+		{"rm 3", "delete", 1},
+		{"de 3", "", 0},
+		{"del 3", "", 0},
+		{"r 3", "", 0},
+		{"l", "", 0},
+		{"nothing", "", 0},
+		{"he", "help", 1},
+		{"quit", "exit", 1},
+	}
+	s := testShell()
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			cmd, n := s.Lookup(strings.Fields(tt.line))
+			got := ""
+			if cmd != nil {
+				got = cmd.Name
+			}
+			if got != tt.want || n != tt.words {
+				t.Errorf("Lookup(%q) = %q, %d; want %q, %d", tt.line, got, n, tt.want, tt.words)
+			}
+		})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	s := testShell()
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"compleet", []string{"'complete'"}},
+		{"del", []string{"'delete'"}},
+		{"lst", []string{"'list projects'", "'list'"}},
+		{"zzzzzz", nil},
+	}
+	for _, tt := range tests {
+		if got := s.suggest(tt.word); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suggest(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestAfterWords(t *testing.T) {
+	tests := []struct {
+		line string
+		n    int
+		want string
+	}{
+		{"add buy milk", 1, "buy milk"},
+		{"  select   project  my proj ", 2, "my proj"},
+		{"list", 1, ""},
+		{"list", 2, ""},
+		{"filter\t#bug  @home", 1, "#bug  @home"},
+	}
+	for _, tt := range tests {
+		if got := afterWords(tt.line, tt.n); got != tt.want {
+			t.Errorf("afterWords(%q, %d) = %q, want %q", tt.line, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"list", "lsit", 2},
+		{"done", "done", 0},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
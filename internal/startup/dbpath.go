@@ -0,0 +1,111 @@
+package startup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/johnjallday/flow-workspace/internal/config"
+)
+
+// Environment variables selecting the database.
+const (
+	DBEnv      = "FLOW_DB"      // path of the database file
+	ProfileEnv = "FLOW_PROFILE" // name of the profile to use
+)
+
+// DataDir returns the directory new databases are created in:
+// $XDG_DATA_HOME/flow-workspace, or ~/.local/share/flow-workspace.
+func DataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, config.AppName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine data directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", config.AppName), nil
+}
+
+// ResolveDBPath returns the database to use. The first of these wins:
+//
+//  1. dbFlag, the --db command line flag
+//  2. the profile named by profile, the --profile flag
+//  3. $FLOW_DB
+//  4. the profile named by $FLOW_PROFILE
+//  5. the settings' profile
+//  6. the settings' db
+//  7. a single fw_*.sqlite in the legacy directory, as used by older versions
+//  8. flow.sqlite in DataDir
+//
+// So a flag overrides the environment, which overrides the settings. A profile
+// not listed in [profiles] uses fw_<profile>.sqlite in the legacy directory if
+// it exists, or else in DataDir. The legacy directory is the settings' app_dir,
+// or else the directory of the binary. Several fw_*.sqlite files there are an
+// error rather than a guess; one of them has to be chosen with --db or a profile.
+func ResolveDBPath(cfg *config.Config, dbFlag string, profile string) (string, error) {
+	if dbFlag != "" {
+		return config.ExpandHome(dbFlag)
+	}
+
+	legacyDir := cfg.AppDir
+	if legacyDir == "" {
+		exePath, err := os.Executable()
+		if err != nil {
+			return "", err
+		}
+		legacyDir = filepath.Dir(exePath)
+	}
+
+	if profile != "" {
+		return profilePath(cfg, legacyDir, profile)
+	}
+	if path := os.Getenv(DBEnv); path != "" {
+		return config.ExpandHome(path)
+	}
+	if profile := os.Getenv(ProfileEnv); profile != "" {
+		return profilePath(cfg, legacyDir, profile)
+	}
+	if cfg.Profile != "" {
+		return profilePath(cfg, legacyDir, cfg.Profile)
+	}
+	if cfg.DB != "" {
+		return cfg.DB, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(legacyDir, "fw_*.sqlite"))
+	if err != nil {
+		return "", err
+	}
+	switch len(matches) {
+	case 0:
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("several databases found (%s); choose one with --db or --profile, $%s or $%s",
+			strings.Join(matches, ", "), DBEnv, ProfileEnv)
+	}
+
+	dataDir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "flow.sqlite"), nil
+}
+
+// profilePath returns the database of the named profile.
+func profilePath(cfg *config.Config, legacyDir string, profile string) (string, error) {
+	if path, ok := cfg.Profiles[profile]; ok {
+		return path, nil
+	}
+	legacy := filepath.Join(legacyDir, "fw_"+profile+".sqlite")
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy, nil
+	}
+	dataDir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "fw_"+profile+".sqlite"), nil
+}
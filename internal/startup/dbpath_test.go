@@ -0,0 +1,50 @@
+package startup
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/johnjallday/flow-workspace/internal/config"
+)
+
+func TestResolveDBPath(t *testing.T) {
+	legacyDir := t.TempDir()
+	dataHome := t.TempDir()
+	profiles := map[string]string{"work": "/db/work.sqlite", "home": "/db/home.sqlite"}
+
+	tests := []struct {
+		name       string
+		dbFlag     string
+		profile    string
+		envDB      string
+		envProfile string
+		cfgDB      string
+		cfgProfile string
+		want       string
+	}{
+		{name: "default", want: filepath.Join(dataHome, config.AppName, "flow.sqlite")},
+		{name: "settings db", cfgDB: "/db/cfg.sqlite", want: "/db/cfg.sqlite"},
+		{name: "settings profile over settings db", cfgDB: "/db/cfg.sqlite", cfgProfile: "home", want: "/db/home.sqlite"},
+		{name: "env profile over settings", envProfile: "work", cfgDB: "/db/cfg.sqlite", cfgProfile: "home", want: "/db/work.sqlite"},
+		{name: "env db over settings profile", envDB: "/db/env.sqlite", cfgProfile: "home", want: "/db/env.sqlite"},
+		{name: "env db over env profile", envDB: "/db/env.sqlite", envProfile: "work", want: "/db/env.sqlite"},
+		{name: "profile flag over env db", profile: "work", envDB: "/db/env.sqlite", cfgProfile: "home", want: "/db/work.sqlite"},
+		{name: "db flag over everything", dbFlag: "/db/flag.sqlite", profile: "work", envDB: "/db/env.sqlite", cfgProfile: "home", want: "/db/flag.sqlite"},
+		{name: "unlisted profile", profile: "other", want: filepath.Join(dataHome, config.AppName, "fw_other.sqlite")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_DATA_HOME", dataHome)
+			t.Setenv(DBEnv, tt.envDB)
+			t.Setenv(ProfileEnv, tt.envProfile)
+			cfg := &config.Config{AppDir: legacyDir, DB: tt.cfgDB, Profile: tt.cfgProfile, Profiles: profiles}
+			got, err := ResolveDBPath(cfg, tt.dbFlag, tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveDBPath = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
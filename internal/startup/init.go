@@ -0,0 +1,42 @@
+package startup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// InitOptions are the inputs of Init.
+type InitOptions struct {
+	Username string // stored in the config table of a new database
+	DBPath   string // database file to create
+	RootDir  string // if set, turned into a root by creating its .config folder
+}
+
+// Init sets up a first run without prompting: it creates the database at
+// opts.DBPath with its schema and config, and the .config folder marking
+// opts.RootDir as a root. Running it again only applies pending migrations and
+// creates what is missing, so it is safe to use in scripts.
+func Init(opts InitOptions) error {
+	if opts.Username == "" {
+		return errors.New("a username is required (--username)")
+	}
+	if opts.DBPath == "" {
+		return errors.New("a database path is required (--db)")
+	}
+
+	if err := createDB(opts.DBPath, opts.Username); err != nil {
+		return fmt.Errorf("failed to create database at %s: %w", opts.DBPath, err)
+	}
+	fmt.Println("Database:", opts.DBPath)
+
+	if opts.RootDir != "" {
+		configDir := filepath.Join(opts.RootDir, ".config")
+		if err := os.MkdirAll(configDir, 0755); err != nil {
+			return fmt.Errorf("failed to create root at %s: %w", opts.RootDir, err)
+		}
+		fmt.Println("Root directory:", opts.RootDir)
+	}
+	return nil
+}
@@ -7,26 +7,17 @@ import (
 	"os"
 	"path/filepath"
 
-	dbtodo "github.com/johnjallday/flow-workspace/internal/db/todo"
+	"github.com/johnjallday/flow-workspace/internal/config"
+	"github.com/johnjallday/flow-workspace/internal/db/migrate"
 	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/term"
 )
 
-// createConfig creates a "config" table and a default config if one doesn't exist.
+// createConfig creates a default config if one doesn't exist.
 func createConfig(db *sql.DB, username string) error {
-	query := `
-    CREATE TABLE IF NOT EXISTS config (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        username TEXT NOT NULL
-    );
-    `
-	_, err := db.Exec(query)
-	if err != nil {
-		return err
-	}
-
 	// Check if a config entry exists.
 	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM config").Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM config").Scan(&count)
 	if err != nil {
 		return err
 	}
@@ -43,13 +34,17 @@ func createConfig(db *sql.DB, username string) error {
 }
 
 // createDB opens (and creates, if necessary) the SQLite database file,
-// creates the todos table, and creates a default configuration if not already present.
-func createDB(dbPath string, username string) {
+// applies the schema migrations, and creates a default configuration if not already present.
+func createDB(dbPath string, username string) error {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return err
+	}
+
 	// Create the database file if it doesn't exist.
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 		file, err := os.Create(dbPath)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		file.Close()
 		log.Printf("Database created at %s", dbPath)
@@ -60,67 +55,98 @@ func createDB(dbPath string, username string) {
 	// Open a connection to the SQLite database.
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer db.Close()
 
-	// Create the todos table.
-	if err := dbtodo.CreateTodoTable(db); err != nil {
-		log.Fatalf("failed to create todos table: %v", err)
+	// Create the tables.
+	if err := applyMigrations(db); err != nil {
+		return err
 	}
 
-	// Create the config table and default config if not exists.
+	// Create the default config if not exists.
 	if err := createConfig(db, username); err != nil {
-		log.Fatalf("failed to create config: %v", err)
+		return fmt.Errorf("failed to create config: %w", err)
 	}
+	return nil
 }
 
-// StartDB determines the binary's directory, checks for an existing SQLite file,
-// and either prompts for a username to create one or opens an existing file.
-func StartDB() string {
-	// Determine the directory of the binary.
-	exePath, err := os.Executable()
-	if err != nil {
-		log.Fatal(err)
+// applyMigrations brings the schema of db up to date and logs the applied migrations.
+func applyMigrations(db *sql.DB) error {
+	applied, err := migrate.Apply(db)
+	for _, m := range applied {
+		log.Printf("Applied migration %d: %s", m.Version, m.Description)
 	}
-	binDir := filepath.Dir(exePath)
-	log.Println("Binary directory:", binDir)
-
-	// Look for existing SQLite files with the pattern fw_*.sqlite.
-	pattern := filepath.Join(binDir, "fw_*.sqlite")
-	matches, err := filepath.Glob(pattern)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
+	return nil
+}
+
+// StartDB opens the SQLite database at cfg.DBPath (see ResolveDBPath), or
+// creates it if it does not exist yet, for the username of the settings or
+// else one prompted for. Without a username and a terminal to prompt on, it
+// fails and points at the init command instead of waiting for input. Pending
+// schema migrations are applied to an existing file, and cfg.Username is
+// filled in from it if the settings leave it out. With dryRun set, the pending
+// migrations are only printed and nothing is created or changed.
+func StartDB(cfg *config.Config, dryRun bool) error {
+	dbPath := cfg.DBPath
+	_, statErr := os.Stat(dbPath)
+	exists := statErr == nil
 
-	var dbPath string
-	if len(matches) == 0 {
-		// No existing SQLite file; prompt for username.
-		var username string
-		fmt.Print("Enter username: ")
-		_, err := fmt.Scanln(&username)
-		if err != nil || username == "" {
-			log.Fatal("username is required")
+	if dryRun {
+		if !exists {
+			fmt.Printf("No database found at %s; a new one would be created at schema version %d.\n", dbPath, migrate.Latest())
+			return nil
 		}
-		dbPath = filepath.Join(binDir, "fw_"+username+".sqlite")
-		createDB(dbPath, username)
-		fmt.Printf("Welcome %s!\n", username)
-	} else {
-		// Use the first matching SQLite file.
-		dbPath = matches[0]
-		// Open the database and retrieve the username from the config table.
 		db, err := sql.Open("sqlite3", dbPath)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		defer db.Close()
+		fmt.Println("Database:", dbPath)
+		return migrate.PrintPending(db)
+	}
 
-		var storedUsername string
-		err = db.QueryRow("SELECT username FROM config LIMIT 1").Scan(&storedUsername)
-		if err != nil {
-			log.Fatalf("failed to retrieve username from config: %v", err)
+	if !exists {
+		// No database yet; prompt for username unless the settings have one.
+		username := cfg.Username
+		if username == "" {
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				return fmt.Errorf("no database at %s and no username to create it with; run 'fw init --username <name>' first", dbPath)
+			}
+			fmt.Print("Enter username: ")
+			if _, err := fmt.Scanln(&username); err != nil || username == "" {
+				return fmt.Errorf("username is required")
+			}
 		}
-		fmt.Printf("Welcome %s!\n", storedUsername)
+		if err := createDB(dbPath, username); err != nil {
+			return err
+		}
+		cfg.Username = username
+		return nil
+	}
+
+	// Open the database and retrieve the username from the config table.
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return err
 	}
-	return dbPath
+	defer db.Close()
+
+	// Bring databases created by older versions up to date.
+	if err := applyMigrations(db); err != nil {
+		return err
+	}
+
+	var storedUsername string
+	err = db.QueryRow("SELECT username FROM config LIMIT 1").Scan(&storedUsername)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve username from config: %w", err)
+	}
+	if cfg.Username == "" {
+		cfg.Username = storedUsername
+	}
+	return nil
 }
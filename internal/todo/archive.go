@@ -0,0 +1,293 @@
+package todo
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/shell"
+	"github.com/olekukonko/tablewriter"
+)
+
+// ArchiveQueryHelp describes the terms accepted by ParseArchiveQuery.
+const ArchiveQueryHelp = "project:<name> workspace:<name> from:<date> to:<date> text"
+
+// ArchiveQuery selects archived tasks. Empty fields match every task; From and
+// To are inclusive bounds on the completion date.
+type ArchiveQuery struct {
+	Project   string
+	Workspace string
+	From      time.Time
+	To        time.Time
+	Text      string
+}
+
+// ArchivedTodo is a task stored in the archive together with the row it is stored in.
+type ArchivedTodo struct {
+	Todo
+	RowID int64
+}
+
+// ParseArchiveQuery parses a query such as "project:flow from:2025-01-01 to:last-week bug".
+// Dates accept everything ParseDate does, with dashes in place of spaces
+// ("next-week"). The remaining words are searched for in the description,
+// tags and notes.
+func ParseArchiveQuery(input string, now time.Time) (ArchiveQuery, error) {
+	var q ArchiveQuery
+	var text []string
+	for _, term := range strings.Fields(input) {
+		key, value, ok := strings.Cut(term, ":")
+		if !ok || value == "" {
+			text = append(text, term)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "project":
+			q.Project = value
+		case "workspace":
+			q.Workspace = value
+		case "from", "to":
+			d, err := parseArchiveDate(value, now)
+			if err != nil {
+				return q, err
+			}
+			if strings.ToLower(key) == "from" {
+				q.From = d
+			} else {
+				q.To = d
+			}
+		default:
+			text = append(text, term)
+		}
+	}
+	q.Text = strings.Join(text, " ")
+	return q, nil
+}
+
+// parseArchiveDate parses the date of a from: or to: term.
+func parseArchiveDate(value string, now time.Time) (time.Time, error) {
+	if d, err := time.Parse("2006-01-02", value); err == nil {
+		return d, nil
+	}
+	return ParseDate(strings.ReplaceAll(value, "-", " "), now)
+}
+
+// QueryArchive returns the archived tasks matching q, most recently completed first.
+func QueryArchive(db *sql.DB, q ArchiveQuery) ([]ArchivedTodo, error) {
+	query := "SELECT id, " + todoColumns + " FROM todos WHERE status = ?"
+	args := []interface{}{statusArchived}
+	if q.Project != "" {
+		query += " AND project_name = ? COLLATE NOCASE"
+		args = append(args, q.Project)
+	}
+	if q.Workspace != "" {
+		query += " AND workspace_name = ? COLLATE NOCASE"
+		args = append(args, q.Workspace)
+	}
+	if !q.From.IsZero() {
+		query += " AND completed_date >= ?"
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		// The bound is inclusive, so compare with the start of the next day.
+		query += " AND completed_date < ?"
+		args = append(args, q.To.AddDate(0, 0, 1))
+	}
+	if q.Text != "" {
+		query += " AND (description LIKE ? OR tags LIKE ? OR notes LIKE ?)"
+		like := "%" + q.Text + "%"
+		args = append(args, like, like, like)
+	}
+	query += " ORDER BY completed_date DESC, id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying archive: %w", err)
+	}
+	defer rows.Close()
+
+	var archived []ArchivedTodo
+	for rows.Next() {
+		var a ArchivedTodo
+		t, err := scanTodo(rows, &a.RowID)
+		if err != nil {
+			return nil, fmt.Errorf("error querying archive: %w", err)
+		}
+		a.Todo = t
+		archived = append(archived, a)
+	}
+	return archived, rows.Err()
+}
+
+// LoadDatabaseTodos returns every task stored in the database, live and
+// archived, optionally limited to one workspace. Archived tasks have Archived set.
+func LoadDatabaseTodos(db *sql.DB, workspace string) ([]Todo, error) {
+	query := todoSelect
+	var args []interface{}
+	if workspace != "" {
+		query += " WHERE workspace_name = ? COLLATE NOCASE"
+		args = append(args, workspace)
+	}
+	query += " ORDER BY id"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var todos []Todo
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error loading tasks: %w", err)
+		}
+		todos = append(todos, t)
+	}
+	return todos, rows.Err()
+}
+
+// RestoreArchived puts an archived task back into the project in projectDir as
+// an open task and removes it from the archive. Projects using the SQLite
+// store get the row back as a live task; otherwise the task is added to the
+// project's todo.md, with a new ID if its old one has been reused.
+func RestoreArchived(db *sql.DB, a ArchivedTodo, projectDir string) error {
+	t := a.Todo
+	t.CompletedDate = time.Time{}
+	t.Ongoing = false
+	t.ParentID, t.Depth = "", 0
+
+	if ProjectStore(projectDir) == StoreSQLite {
+		service, err := NewSQLiteTodoService(db, projectDir)
+		if err != nil {
+			return err
+		}
+		if _, err := service.get(t.ID); t.ID == "" || err == nil {
+			ids, err := service.ids()
+			if err != nil {
+				return err
+			}
+			t.ID = newTodoID(ids)
+		}
+		if err := service.insert(t, 0); err != nil {
+			return err
+		}
+	} else {
+		todoFile := filepath.Join(projectDir, "todo.md")
+		doc, err := LoadDocument(todoFile)
+		if os.IsNotExist(err) {
+			doc = ParseDocument("# todo\n")
+		} else if err != nil {
+			return fmt.Errorf("failed to read '%s': %w", todoFile, err)
+		}
+		added, err := doc.AddLine(formatTodo(t))
+		if err != nil {
+			return fmt.Errorf("invalid task: %w", err)
+		}
+		// The notes are written below the task line.
+		added.Notes = t.Notes
+		if err := doc.Update(added); err != nil {
+			return err
+		}
+		if err := doc.Save(todoFile); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.Exec("DELETE FROM todos WHERE id = ? AND status = ?", a.RowID, statusArchived); err != nil {
+		return fmt.Errorf("error removing task from the archive: %w", err)
+	}
+	return nil
+}
+
+// PrintArchive prints archived tasks in a table.
+func PrintArchive(archived []ArchivedTodo) {
+	if len(archived) == 0 {
+		fmt.Println("No archived TODOs found.")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.SetHeader([]string{"No.", "ID", "Completed", "Description", "Tags", "Project", "Workspace"})
+	for i, a := range archived {
+		completed := ""
+		if !a.CompletedDate.IsZero() {
+			completed = a.CompletedDate.Format("2006-01-02")
+		}
+		table.Append([]string{strconv.Itoa(i + 1), a.ID, completed, a.Description, FormatTags(a.Todo), a.ProjectName, a.WorkspaceName})
+	}
+	table.Render()
+}
+
+// BrowseArchive asks for an archive query, adds it to scope, prints the
+// matching archived tasks and offers to restore one of them. projectDir
+// returns the directory of the project an archived task belongs to.
+func BrowseArchive(db *sql.DB, sh *shell.Shell, scope ArchiveQuery, projectDir func(a ArchivedTodo) (string, error)) {
+	input, err := sh.Ask(fmt.Sprintf("Enter archive query (%s) or leave empty for all: ", ArchiveQueryHelp))
+	if err != nil {
+		fmt.Println("Error reading query:", err)
+		return
+	}
+	q, err := ParseArchiveQuery(input, time.Now())
+	if err != nil {
+		fmt.Println("Invalid query:", err)
+		return
+	}
+	// The scope of the REPL can't be widened by the query.
+	if scope.Project != "" {
+		q.Project = scope.Project
+	}
+	if scope.Workspace != "" {
+		q.Workspace = scope.Workspace
+	}
+
+	archived, err := QueryArchive(db, q)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	PrintArchive(archived)
+	if len(archived) == 0 {
+		return
+	}
+
+	input, err = sh.Ask("Enter the number of a task to restore, or leave empty to go back: ")
+	if err != nil {
+		fmt.Println("Error reading input:", err)
+		return
+	}
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return
+	}
+	n, err := strconv.Atoi(input)
+	if err != nil || n < 1 || n > len(archived) {
+		fmt.Println("Invalid task number.")
+		return
+	}
+
+	a := archived[n-1]
+	dir, err := projectDir(a)
+	if err != nil {
+		fmt.Println("Error locating project:", err)
+		return
+	}
+	if err := RestoreArchived(db, a, dir); err != nil {
+		fmt.Println("Error restoring task:", err)
+		return
+	}
+	fmt.Printf("Task \"%s\" restored to %s.\n", a.Description, dir)
+}
+
+// ProjectArchiveScope returns the archive scope of the project in projectDir.
+func ProjectArchiveScope(projectDir string) ArchiveQuery {
+	projectDir = filepath.Clean(projectDir)
+	return ArchiveQuery{Project: filepath.Base(projectDir), Workspace: filepath.Base(filepath.Dir(projectDir))}
+}
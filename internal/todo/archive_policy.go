@@ -0,0 +1,123 @@
+package todo
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/config"
+)
+
+// ProjectArchivePolicy returns policy with the archive settings of the
+// project_info.toml in projectDir applied on top of it. Invalid project
+// settings are reported and ignored.
+func ProjectArchivePolicy(policy config.ArchivePolicy, projectDir string) config.ArchivePolicy {
+	info := loadProjectSettings(projectDir)
+	project := policy
+	if info.Archive != "" {
+		project.Mode = strings.ToLower(strings.TrimSpace(info.Archive))
+	}
+	if info.ArchiveAfterDays != nil {
+		project.AfterDays = *info.ArchiveAfterDays
+	}
+	if info.ArchiveKeepLast != nil {
+		project.KeepLast = *info.ArchiveKeepLast
+	}
+	if info.ArchiveQuiet != nil {
+		project.Quiet = *info.ArchiveQuiet
+	}
+	if err := project.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring the archive settings of %s: %v\n", projectDir, err)
+		return policy
+	}
+	return project
+}
+
+// ArchiveFinishedTodos moves the finished tasks of service selected by policy
+// out of the live list: from the todo file into the database for the file
+// store, or by marking them archived for the SQLite store. It returns the
+// archived tasks for PrintArchiveReport. With manual unset it is a REPL being
+// opened, which only archives in the auto mode; the archive-finished command
+// sets manual and archives in the manual mode too.
+func ArchiveFinishedTodos(service TodoService, todoPath string, conn *sql.DB, policy config.ArchivePolicy, manual bool) ([]Todo, error) {
+	switch {
+	case policy.Mode == config.ArchiveNever:
+		if manual {
+			fmt.Println("Archiving is disabled for this project (archive = \"never\").")
+		}
+		return nil, nil
+	case policy.Mode == config.ArchiveManual && !manual:
+		return nil, nil
+	}
+
+	if s, ok := service.(*SQLiteTodoService); ok {
+		return s.ArchiveFinished(policy, time.Now())
+	}
+	return MigrateFinishedTodos(todoPath, conn, policy, time.Now())
+}
+
+// selectArchivable returns the indexes of the tasks in todos that policy
+// archives at now: finished before the policy's cutoff and not among the
+// KeepLast most recently finished tasks. A task whose subtasks are not all
+// archived with it stays, so that none of them loses its parent.
+func selectArchivable(todos []Todo, policy config.ArchivePolicy, now time.Time) map[int]bool {
+	var finished []int
+	for i, t := range todos {
+		if !t.CompletedDate.IsZero() {
+			finished = append(finished, i)
+		}
+	}
+	sort.SliceStable(finished, func(a, b int) bool {
+		return todos[finished[a]].CompletedDate.After(todos[finished[b]].CompletedDate)
+	})
+
+	cutoff := policy.Cutoff(now)
+	selected := make(map[int]bool)
+	for n, i := range finished {
+		if n >= policy.KeepLast && todos[i].CompletedDate.Before(cutoff) {
+			selected[i] = true
+		}
+	}
+
+	// Keep the ancestors of every task that stays.
+	index := make(map[string]int)
+	for i, t := range todos {
+		if t.ID != "" {
+			index[t.ID] = i
+		}
+	}
+	for i, t := range todos {
+		if selected[i] {
+			continue
+		}
+		for id := t.ParentID; id != ""; {
+			p, ok := index[id]
+			if !ok || !selected[p] {
+				// Its ancestors are kept when the loop reaches it.
+				break
+			}
+			delete(selected, p)
+			id = todos[p].ParentID
+		}
+	}
+	return selected
+}
+
+// PrintArchiveReport lists the tasks archived by ArchiveFinishedTodos. Nothing
+// is printed when no task was archived unless the archive was requested with
+// a command.
+func PrintArchiveReport(archived []Todo, manual bool) {
+	if len(archived) == 0 {
+		if manual {
+			fmt.Println("No finished todos to archive.")
+		}
+		return
+	}
+	fmt.Printf("Archived %d finished todo(s):\n", len(archived))
+	for _, t := range archived {
+		fmt.Printf(" - %s (completed %s)\n", t.Description, t.CompletedDate.Format("2006-01-02"))
+	}
+}
@@ -0,0 +1,154 @@
+package todo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/config"
+)
+
+func TestSelectArchivable(t *testing.T) {
+	now := date("2026-03-01")
+	old, older := date("2026-01-02"), date("2026-01-01")
+	tests := []struct {
+		name   string
+		policy config.ArchivePolicy
+		todos  []Todo
+		want   []string
+	}{
+		{
+			name:  "finished tasks",
+			todos: []Todo{{ID: "a", CompletedDate: old}, {ID: "b"}, {ID: "c", CompletedDate: older}},
+			want:  []string{"a", "c"},
+		},
+		{
+			name:   "recently finished",
+			policy: config.ArchivePolicy{AfterDays: 30},
+			todos:  []Todo{{ID: "a", CompletedDate: date("2026-02-20")}, {ID: "b", CompletedDate: old}},
+			want:   []string{"b"},
+		},
+		{
+			name:   "keep last",
+			policy: config.ArchivePolicy{KeepLast: 1},
+			todos:  []Todo{{ID: "a", CompletedDate: older}, {ID: "b", CompletedDate: old}},
+			want:   []string{"a"},
+		},
+		{
+			name:  "finished parent with an open subtask",
+			todos: []Todo{{ID: "p", CompletedDate: old}, {ID: "c", ParentID: "p", Depth: 1}},
+			want:  nil,
+		},
+		{
+			name:  "finished parent with finished subtasks",
+			todos: []Todo{{ID: "p", CompletedDate: old}, {ID: "c", ParentID: "p", Depth: 1, CompletedDate: older}},
+			want:  []string{"c", "p"},
+		},
+		{
+			name:   "finished parent with a subtask kept by keep last",
+			policy: config.ArchivePolicy{KeepLast: 1},
+			todos:  []Todo{{ID: "p", CompletedDate: older}, {ID: "c", ParentID: "p", Depth: 1, CompletedDate: old}},
+			want:   nil,
+		},
+		{
+			name: "open grandchild",
+			todos: []Todo{
+				{ID: "g", CompletedDate: old},
+				{ID: "p", ParentID: "g", Depth: 1, CompletedDate: old},
+				{ID: "c", ParentID: "p", Depth: 2},
+				{ID: "s", ParentID: "g", Depth: 1, CompletedDate: old},
+			},
+			want: []string{"s"},
+		},
+		{
+			name:  "finished subtask of an open parent",
+			todos: []Todo{{ID: "p"}, {ID: "c", ParentID: "p", Depth: 1, CompletedDate: old}},
+			want:  []string{"c"},
+		},
+	}
+	for _, tt := range tests {
+		var got []string
+		for i := range selectArchivable(tt.todos, tt.policy, now) {
+			got = append(got, tt.todos[i].ID)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: selectArchivable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// archiveTree is a todo.md whose finished parent has an open subtask.
+const archiveTree = "# todo\n\n" +
+	"- [ ] other #created:2026-01-01 #id:aaaa11\n" +
+	"- [x] parent #created:2026-01-01 #completed:2026-01-02 #id:bbbb22\n" +
+	"  - [ ] child #created:2026-01-01 #id:cccc33\n" +
+	"- [x] done #created:2026-01-01 #completed:2026-01-02 #id:dddd44\n"
+
+// checkArchivedTree checks that only the task without subtasks of
+// archiveTree was archived and the child kept its parent.
+func checkArchivedTree(t *testing.T, archived []Todo, todos []Todo) {
+	t.Helper()
+	if len(archived) != 1 || archived[0].ID != "dddd44" {
+		t.Errorf("archived %v, want only dddd44", archived)
+	}
+	if i := FindTodoByID(todos, "bbbb22"); i < 0 {
+		t.Errorf("finished parent of an open subtask was archived")
+	}
+	if i := FindTodoByID(todos, "cccc33"); i < 0 || todos[i].ParentID != "bbbb22" {
+		t.Errorf("child = %+v, want it kept below bbbb22", todos)
+	}
+}
+
+func TestMigrateFinishedTodosKeepsParentsOfOpenSubtasks(t *testing.T) {
+	conn := openTestDB(t)
+	todoFile := filepath.Join(t.TempDir(), "ws", "proj", "todo.md")
+	if err := os.MkdirAll(filepath.Dir(todoFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileContent(todoFile, archiveTree); err != nil {
+		t.Fatal(err)
+	}
+
+	archived, err := MigrateFinishedTodos(todoFile, conn, config.ArchivePolicy{Mode: config.ArchiveAuto}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	todos, err := LoadAllTodos(todoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkArchivedTree(t, archived, todos)
+}
+
+func TestSQLiteArchiveFinishedKeepsParentsOfOpenSubtasks(t *testing.T) {
+	conn := openTestDB(t)
+	projectDir := filepath.Join(t.TempDir(), "ws", "proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	todoFile := filepath.Join(projectDir, "todo.md")
+	if err := WriteFileContent(todoFile, archiveTree); err != nil {
+		t.Fatal(err)
+	}
+	service, err := NewSQLiteTodoService(conn, projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncProject(conn, todoFile, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	archived, err := service.ArchiveFinished(config.ArchivePolicy{Mode: config.ArchiveAuto}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	todos, err := service.ListTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkArchivedTree(t, archived, todos)
+}
@@ -0,0 +1,79 @@
+package todo
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/db/migrate"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// openTestDB returns a migrated database in a temporary directory.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "flow.sqlite")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := migrate.Apply(conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestRestoreArchivedKeepsNotes(t *testing.T) {
+	conn := openTestDB(t)
+	projectDir := filepath.Join(t.TempDir(), "ws", "proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	todoFile := filepath.Join(projectDir, "todo.md")
+	if err := WriteFileContent(todoFile, "# todo\n\n- [ ] other #id:bbbb\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := Todo{
+		ID:            "aaaa",
+		Description:   "finished",
+		CompletedDate: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		Notes:         []string{"first note", "second note"},
+		ProjectName:   "proj",
+		WorkspaceName: "ws",
+	}
+	if err := InsertTodo(conn, done); err != nil {
+		t.Fatal(err)
+	}
+	archived, err := QueryArchive(conn, ProjectArchiveScope(projectDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 1 {
+		t.Fatalf("got %d archived tasks, want 1", len(archived))
+	}
+	if err := RestoreArchived(conn, archived[0], projectDir); err != nil {
+		t.Fatal(err)
+	}
+
+	todos, err := LoadAllTodos(todoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := FindTodoByID(todos, "aaaa")
+	if i < 0 {
+		t.Fatalf("restored task is missing from %v", todos)
+	}
+	if got := strings.Join(todos[i].Notes, "|"); got != "first note|second note" {
+		t.Errorf("restored notes = %q", got)
+	}
+	if !todos[i].CompletedDate.IsZero() {
+		t.Error("restored task is still completed")
+	}
+	if left, _ := QueryArchive(conn, ArchiveQuery{}); len(left) != 0 {
+		t.Errorf("%d tasks left in the archive", len(left))
+	}
+}
@@ -0,0 +1,104 @@
+package todo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MarkBlocked sets Blocked on every unfinished task of todos that waits for
+// an unfinished task in pool. A blocker is given as "id" for a task of the same
+// project or as "project/id" for a task of another project of the workspace,
+// since IDs are only unique within a project; tasks belong to the project in
+// their ProjectName. Blockers that can't be found in pool are assumed to be
+// done, e.g. because they have already been archived.
+func MarkBlocked(todos []Todo, pool []Todo) {
+	open := make(map[string]bool)
+	for _, t := range pool {
+		if t.ID != "" {
+			open[blockerKey(t.ProjectName, t.ID)] = t.CompletedDate.IsZero()
+		}
+	}
+	for i := range todos {
+		todos[i].Blocked = false
+		if !todos[i].CompletedDate.IsZero() {
+			continue
+		}
+		for _, ref := range todos[i].BlockedBy {
+			project, id, ok := strings.Cut(ref, "/")
+			if !ok {
+				project, id = todos[i].ProjectName, ref
+			}
+			if open[blockerKey(project, id)] {
+				todos[i].Blocked = true
+				break
+			}
+		}
+	}
+}
+
+// blockerKey returns the key MarkBlocked looks a task up by.
+func blockerKey(project, id string) string {
+	return strings.ToLower(project) + "/" + normalizeID(id)
+}
+
+// ReadyTodos returns the tasks that can be started now: unfinished, not yet
+// ongoing and not waiting for another task. Call MarkBlocked first.
+func ReadyTodos(todos []Todo) []Todo {
+	var ready []Todo
+	for _, t := range todos {
+		if t.CompletedDate.IsZero() && !t.Ongoing && !t.Blocked {
+			ready = append(ready, t)
+		}
+	}
+	return ready
+}
+
+// siblingTodos loads the tasks of the other projects in the same workspace as
+// todoFilePath, i.e. the todo.md files one directory level next to its project.
+// Tasks without a "#project" tag get the name of their project's directory.
+// The files are only read, never rewritten.
+func siblingTodos(todoFilePath string) []Todo {
+	projectDir := filepath.Dir(todoFilePath)
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(projectDir), "*", "todo.md"))
+	if err != nil {
+		return nil
+	}
+
+	var todos []Todo
+	for _, match := range matches {
+		if filepath.Clean(match) == filepath.Clean(todoFilePath) {
+			continue
+		}
+		content, err := os.ReadFile(match)
+		if err != nil {
+			continue
+		}
+		todos = append(todos, withProjectName(ParseDocument(string(content)).Todos(), filepath.Base(filepath.Dir(match)))...)
+	}
+	return todos
+}
+
+// withProjectName returns a copy of todos in which the tasks without a project
+// name have the given one.
+func withProjectName(todos []Todo, project string) []Todo {
+	named := make([]Todo, len(todos))
+	for i, t := range todos {
+		if t.ProjectName == "" {
+			t.ProjectName = project
+		}
+		named[i] = t
+	}
+	return named
+}
+
+// parseIDList splits a comma separated list of task IDs.
+func parseIDList(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = normalizeID(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,86 @@
+package todo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMarkBlocked(t *testing.T) {
+	done := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	pool := []Todo{
+		{ID: "aaaa", ProjectName: "web"},
+		{ID: "bbbb", ProjectName: "web", CompletedDate: done},
+		{ID: "aaaa", ProjectName: "api", CompletedDate: done},
+		{ID: "cccc", ProjectName: "api"},
+	}
+	tests := []struct {
+		name string
+		todo Todo
+		want bool
+	}{
+		{"open blocker in own project", Todo{ProjectName: "web", BlockedBy: []string{"aaaa"}}, true},
+		{"finished blocker in own project", Todo{ProjectName: "web", BlockedBy: []string{"bbbb"}}, false},
+		{"id reused in other project", Todo{ProjectName: "api", BlockedBy: []string{"aaaa"}}, false},
+		{"bare id of other project", Todo{ProjectName: "web", BlockedBy: []string{"cccc"}}, false},
+		{"qualified open blocker", Todo{ProjectName: "web", BlockedBy: []string{"api/cccc"}}, true},
+		{"qualified finished blocker", Todo{ProjectName: "web", BlockedBy: []string{"api/aaaa"}}, false},
+		{"qualified case-insensitive", Todo{ProjectName: "api", BlockedBy: []string{"WEB/aaaa"}}, true},
+		{"unknown blocker", Todo{ProjectName: "web", BlockedBy: []string{"zzzz"}}, false},
+		{"finished task", Todo{ProjectName: "web", BlockedBy: []string{"aaaa"}, CompletedDate: done}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todos := []Todo{tt.todo}
+			MarkBlocked(todos, pool)
+			if todos[0].Blocked != tt.want {
+				t.Errorf("Blocked = %v, want %v", todos[0].Blocked, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadyTodos(t *testing.T) {
+	todos := []Todo{
+		{ID: "aaaa"},
+		{ID: "bbbb", Ongoing: true},
+		{ID: "cccc", Blocked: true},
+		{ID: "dddd", CompletedDate: time.Now()},
+		{ID: "eeee"},
+	}
+	ready := ReadyTodos(todos)
+	if len(ready) != 2 || ready[0].ID != "aaaa" || ready[1].ID != "eeee" {
+		t.Errorf("ReadyTodos = %v", ready)
+	}
+}
+
+func TestFileTodoServiceBlockedAcrossProjects(t *testing.T) {
+	ws := t.TempDir()
+	files := map[string]string{
+		"web": "- [ ] page #blocked-by:aaaa #id:bbbb\n- [ ] form #blocked-by:api/aaaa #id:cccc\n- [x] done #completed:2026-01-02 #id:dddd\n",
+		"api": "- [ ] endpoint #id:aaaa\n",
+	}
+	for project, content := range files {
+		if err := os.MkdirAll(filepath.Join(ws, project), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteFileContent(filepath.Join(ws, project, "todo.md"), content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	todos, err := NewFileTodoService(filepath.Join(ws, "web", "todo.md")).ListTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"bbbb": false, "cccc": true, "dddd": false}
+	for _, td := range todos {
+		if td.Blocked != want[td.ID] {
+			t.Errorf("task %s: Blocked = %v, want %v", td.ID, td.Blocked, want[td.ID])
+		}
+		if td.ProjectName != "" {
+			t.Errorf("task %s: ProjectName = %q, want it unset", td.ID, td.ProjectName)
+		}
+	}
+}
@@ -4,55 +4,41 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // tagRegex is used to extract tags from a task line.
-var tagRegex = regexp.MustCompile(`#(\w+):([^\s#]+)`)
+var tagRegex = regexp.MustCompile(`#([\w\-]+):([^\s#]+)`)
+
+// priorityRegex matches a todo.txt style "(A)" priority at the start of a description.
+var priorityRegex = regexp.MustCompile(`^\(([A-Da-d])\)\s+`)
+
+// labelRegex matches bare "#label" tags and contextRegex "@context" markers.
+// Both must start a word, so "C#" or an e-mail address are left alone.
+var (
+	labelRegex   = regexp.MustCompile(`(^|\s)#([A-Za-z][\w\-/]*)`)
+	contextRegex = regexp.MustCompile(`(^|\s)@([A-Za-z][\w\-/]*)`)
+)
 
 // LoadAllTodos reads a todo file (e.g. "todo.md") and parses its tasks.
-// If a completed task is missing the "#completed" tag, it updates the line and re-saves the file.
+// If a completed task is missing the "#completed" tag, or a task has no unique
+// "#id" tag, it updates the line and re-saves the file.
 func LoadAllTodos(filename string) ([]Todo, error) {
-	content, err := os.ReadFile(filename)
+	doc, err := LoadDocument(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	lines := strings.Split(string(content), "\n")
-	var todos []Todo
-	modified := false // flag to indicate if any line was updated
-
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		// Skip blank lines or comments.
-		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-			continue
-		}
-		t, err := parseTodo(trimmed)
-		if err != nil {
-			fmt.Printf("Skipping invalid task line: %s\n", trimmed)
-			continue
-		}
-		todos = append(todos, t)
-		// For completed tasks, check if the original line is missing the "#completed" tag.
-		if !t.CompletedDate.IsZero() && !strings.Contains(line, "#completed:") {
-			// Replace the line with the correctly formatted one.
-			lines[i] = formatTodo(t)
-			modified = true
+	// If any modifications were made, re-save the file with updated tags.
+	if doc.Modified() {
+		if err := doc.Save(filename); err != nil {
+			return doc.Todos(), fmt.Errorf("failed to update file with missing tags: %w", err)
 		}
 	}
 
-	// If any modifications were made, re-save the file with updated completed tags.
-	if modified {
-		newContent := strings.Join(lines, "\n")
-		err = WriteFileContent(filename, newContent)
-		if err != nil {
-			return todos, fmt.Errorf("failed to update file with completed tags: %w", err)
-		}
-	}
-
-	return todos, nil
+	return doc.Todos(), nil
 }
 
 // parseTodo converts a single todo line into a Todo struct.
@@ -79,12 +65,21 @@ func parseTodo(line string) (Todo, error) {
 	}
 
 	line = strings.TrimSpace(line)
+
+	// A leading "(A)" sets the priority, todo.txt style.
+	if m := priorityRegex.FindStringSubmatch(line); m != nil {
+		t.Priority, _ = ParsePriority(m[1])
+		line = line[len(m[0]):]
+	}
+
 	tags := tagRegex.FindAllStringSubmatch(line, -1)
 	for _, tag := range tags {
 		if len(tag) == 3 {
 			key := strings.ToLower(tag[1])
 			value := tag[2]
 			switch key {
+			case "id":
+				t.ID = normalizeID(value)
 			case "created":
 				d, err := time.Parse("2006-01-02", value)
 				if err != nil {
@@ -92,11 +87,29 @@ func parseTodo(line string) (Todo, error) {
 				}
 				t.CreatedDate = d
 			case "due":
-				d, err := time.Parse("2006-01-02", value)
+				d, hasTime, zone, err := parseDueTag(value)
 				if err != nil {
-					return t, fmt.Errorf("invalid due_date format")
+					keepInvalidTag(&t, tag[1], value, err)
+					continue
 				}
-				t.DueDate = d
+				t.DueDate, t.DueHasTime, t.DueZone = d, hasTime, zone
+			case "p":
+				p, err := ParsePriority(value)
+				if err != nil {
+					keepInvalidTag(&t, tag[1], value, err)
+					continue
+				}
+				t.Priority = p
+			case "every":
+				if err := ValidateRecurrence(value); err != nil {
+					keepInvalidTag(&t, tag[1], value, err)
+					continue
+				}
+				t.Recur = strings.ToLower(value)
+			case "series":
+				t.Series = normalizeID(value)
+			case "blocked-by":
+				t.BlockedBy = append(t.BlockedBy, parseIDList(value)...)
 			case "project":
 				t.ProjectName = value
 			case "workspace":
@@ -108,16 +121,36 @@ func parseTodo(line string) (Todo, error) {
 					return t, fmt.Errorf("invalid completed_date format")
 				}
 				t.CompletedDate = d
+			default:
+				// Keep tags we don't understand so they survive a rewrite.
+				t.Tags = append(t.Tags, Tag{Key: tag[1], Value: value})
 			}
 		}
 	}
 
 	// Remove the tags from the description.
 	desc := tagRegex.ReplaceAllString(line, "")
+
+	// Collect bare labels and contexts and remove them as well.
+	for _, m := range labelRegex.FindAllStringSubmatch(desc, -1) {
+		t.Labels = append(t.Labels, m[2])
+	}
+	for _, m := range contextRegex.FindAllStringSubmatch(desc, -1) {
+		t.Contexts = append(t.Contexts, m[2])
+	}
+	desc = labelRegex.ReplaceAllString(desc, "")
+	desc = contextRegex.ReplaceAllString(desc, "")
 	t.Description = strings.TrimSpace(desc)
 	return t, nil
 }
 
+// keepInvalidTag keeps a tag whose value can't be used as a generic tag, so the
+// task is still listed and the tag is written back as it was, and warns about it.
+func keepInvalidTag(t *Todo, key, value string, err error) {
+	fmt.Fprintf(os.Stderr, "Warning: keeping invalid tag #%s:%s as written: %v\n", key, value, err)
+	t.Tags = append(t.Tags, Tag{Key: key, Value: value})
+}
+
 // WriteFileContent writes content to a file with 0644 permissions.
 func WriteFileContent(filename, content string) error {
 	return os.WriteFile(filename, []byte(content), 0644)
@@ -133,56 +166,19 @@ func ReadFileContent(filename string) (string, error) {
 }
 
 // SaveTodos writes the given slice of todos back to the specified file.
-// Each todo is formatted as a single line in the todo file.
+// Tasks are matched to the lines already in the file by ID: unchanged tasks and
+// all non-task lines are kept byte-for-byte, changed tasks are rewritten in place,
+// tasks missing from todos are removed and new tasks are appended.
 func SaveTodos(filename string, todos []Todo) error {
-	var lines []string
-
-	// Iterate over todos and build a string for each one.
-	for _, t := range todos {
-		var lineBuilder strings.Builder
-
-		// Use different marker based on task status.
-		if !t.CompletedDate.IsZero() {
-			lineBuilder.WriteString("- [x] ")
-		} else if t.Ongoing {
-			lineBuilder.WriteString("- [~] ")
-		} else {
-			lineBuilder.WriteString("- [ ] ")
-		}
-
-		// Write the description.
-		lineBuilder.WriteString(t.Description)
-
-		// Append tags for created, due, project, and workspace if available.
-		if !t.CreatedDate.IsZero() {
-			lineBuilder.WriteString(" #created:")
-			lineBuilder.WriteString(t.CreatedDate.Format("2006-01-02"))
-		}
-		if !t.DueDate.IsZero() {
-			lineBuilder.WriteString(" #due:")
-			lineBuilder.WriteString(t.DueDate.Format("2006-01-02"))
-		}
-		if t.ProjectName != "" {
-			lineBuilder.WriteString(" #project:")
-			lineBuilder.WriteString(t.ProjectName)
-		}
-		if t.WorkspaceName != "" {
-			lineBuilder.WriteString(" #workspace:")
-			lineBuilder.WriteString(t.WorkspaceName)
-		}
-
-		// Append the "#completed" tag if the task is complete.
-		if !t.CompletedDate.IsZero() {
-			lineBuilder.WriteString(" #completed:")
-			lineBuilder.WriteString(t.CompletedDate.Format("2006-01-02"))
+	doc, err := LoadDocument(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
 		}
-
-		lines = append(lines, lineBuilder.String())
+		doc = ParseDocument("")
 	}
-
-	// Join all lines into a single string with newline separation.
-	content := strings.Join(lines, "\n")
-	return WriteFileContent(filename, content)
+	doc.Replace(todos)
+	return doc.Save(filename)
 }
 
 // formatTodo builds and returns a markdown-formatted string for a Todo.
@@ -199,8 +195,12 @@ func formatTodo(t Todo) string {
 		lineBuilder.WriteString("- [ ] ")
 	}
 
-	// Write the description.
+	// Write the description followed by labels and contexts.
 	lineBuilder.WriteString(t.Description)
+	if labels := FormatLabels(t); labels != "" {
+		lineBuilder.WriteString(" ")
+		lineBuilder.WriteString(labels)
+	}
 
 	// Append tags for created, due, project, and workspace if available.
 	if !t.CreatedDate.IsZero() {
@@ -209,7 +209,23 @@ func formatTodo(t Todo) string {
 	}
 	if !t.DueDate.IsZero() {
 		lineBuilder.WriteString(" #due:")
-		lineBuilder.WriteString(t.DueDate.Format("2006-01-02"))
+		lineBuilder.WriteString(formatDueTag(t))
+	}
+	if t.Priority != 0 {
+		lineBuilder.WriteString(" #p:")
+		lineBuilder.WriteString(strconv.Itoa(t.Priority))
+	}
+	if t.Recur != "" {
+		lineBuilder.WriteString(" #every:")
+		lineBuilder.WriteString(t.Recur)
+	}
+	if t.Series != "" {
+		lineBuilder.WriteString(" #series:")
+		lineBuilder.WriteString(t.Series)
+	}
+	if len(t.BlockedBy) > 0 {
+		lineBuilder.WriteString(" #blocked-by:")
+		lineBuilder.WriteString(strings.Join(t.BlockedBy, ","))
 	}
 	if t.ProjectName != "" {
 		lineBuilder.WriteString(" #project:")
@@ -224,9 +240,46 @@ func formatTodo(t Todo) string {
 		lineBuilder.WriteString(" #completed:")
 		lineBuilder.WriteString(t.CompletedDate.Format("2006-01-02"))
 	}
+	// Write back any tags the parser did not recognise, in their original order.
+	for _, tag := range t.Tags {
+		lineBuilder.WriteString(" #")
+		lineBuilder.WriteString(tag.Key)
+		lineBuilder.WriteString(":")
+		lineBuilder.WriteString(tag.Value)
+	}
+	if t.ID != "" {
+		lineBuilder.WriteString(" #id:")
+		lineBuilder.WriteString(t.ID)
+	}
 	return lineBuilder.String()
 }
 
+// FormatLabels returns the bare labels and contexts of a task as they are
+// written in todo.md, e.g. "#bug #ui @home".
+func FormatLabels(t Todo) string {
+	var parts []string
+	for _, l := range t.Labels {
+		parts = append(parts, "#"+l)
+	}
+	for _, c := range t.Contexts {
+		parts = append(parts, "@"+c)
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatTags returns the bare labels, contexts and generic "#key:value" tags
+// of a task as a single space separated string.
+func FormatTags(t Todo) string {
+	parts := []string{}
+	if labels := FormatLabels(t); labels != "" {
+		parts = append(parts, labels)
+	}
+	for _, tag := range t.Tags {
+		parts = append(parts, "#"+tag.Key+":"+tag.Value)
+	}
+	return strings.Join(parts, " ")
+}
+
 // FilterTodosByOngoing returns a list of todos that are marked as ongoing.
 func FilterTodosByOngoing(todos []Todo) []Todo {
 	var ongoingTodos []Todo
@@ -0,0 +1,64 @@
+package todo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTodo(t *testing.T) {
+	tests := []struct {
+		line string
+		want Todo
+	}{
+		{
+			line: "- [ ] write docs #id:aaaa",
+			want: Todo{ID: "aaaa", Description: "write docs"},
+		},
+		{
+			line: "- [~] (B) fix bug #ui @work #color:red",
+			want: Todo{Description: "fix bug", Ongoing: true, Priority: 2, Labels: []string{"ui"}, Contexts: []string{"work"}, Tags: []Tag{{Key: "color", Value: "red"}}},
+		},
+		{
+			line: "- [ ] ship #p:1 #blocked-by:aaaa,bbbb",
+			want: Todo{Description: "ship", Priority: 1, BlockedBy: []string{"aaaa", "bbbb"}},
+		},
+		{
+			line: "- [ ] bad priority #p:x",
+			want: Todo{Description: "bad priority", Tags: []Tag{{Key: "p", Value: "x"}}},
+		},
+		{
+			line: "- [ ] water plants #every:Weekly",
+			want: Todo{Description: "water plants", Recur: "weekly"},
+		},
+		{
+			line: "- [ ] bad rule #every:sometimes #id:aaaa",
+			want: Todo{ID: "aaaa", Description: "bad rule", Tags: []Tag{{Key: "every", Value: "sometimes"}}},
+		},
+		{
+			line: "- [ ] bad date #due:someday",
+			want: Todo{Description: "bad date", Tags: []Tag{{Key: "due", Value: "someday"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parseTodo(tt.line)
+			if err != nil {
+				t.Fatalf("parseTodo(%q) error: %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTodo(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+			if again, _ := parseTodo(formatTodo(got)); !reflect.DeepEqual(again, got) {
+				t.Errorf("formatTodo(%+v) does not parse back: %+v", got, again)
+			}
+		})
+	}
+}
+
+func TestParseTodoInvalidLine(t *testing.T) {
+	for _, line := range []string{"", "plain text", "- task", "- [?] odd marker"} {
+		if _, err := parseTodo(line); err == nil {
+			t.Errorf("parseTodo(%q) succeeded", line)
+		}
+	}
+}
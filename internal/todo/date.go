@@ -0,0 +1,114 @@
+package todo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WeekStart is the first day of the week, from the week_start setting that the
+// weekly review uses as well. ParseDate ends the week on the day before it.
+var WeekStart = time.Saturday
+
+// DateInputHelp describes the formats accepted by ParseDate, for use in prompts.
+const DateInputHelp = "YYYY-MM-DD, today, tomorrow, fri, next week, +3d, end of month, ..., optionally with a time and zone like 'thu 14:00 UTC'"
+
+// ParseDate turns user input into an absolute date relative to now. Besides
+// "YYYY-MM-DD" it accepts:
+//
+//	today, tomorrow, yesterday
+//	mon ... sun, monday ... sunday   the next such day (today if it matches)
+//	next mon ... next sun            the next such day after today
+//	next week, next month, next year the first day of that period
+//	+3d, +2w, +1m, +1y               an offset from today
+//	in 3 days, in 2 weeks, ...       the same, spelled out
+//	end of week (eow)                the coming last day of the week, the day
+//	                                 before WeekStart (Friday by default)
+//	end of month (eom)               the last day of this month
+//	end of year (eoy)                December 31st
+//
+// The result is a date at midnight UTC, like dates parsed from todo.md.
+func ParseDate(input string, now time.Time) (time.Time, error) {
+	value := strings.Join(strings.Fields(strings.ToLower(input)), " ")
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	if d, err := time.Parse("2006-01-02", value); err == nil {
+		return d, nil
+	}
+
+	switch value {
+	case "today", "tod":
+		return today, nil
+	case "tomorrow", "tmr", "tom":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "next week":
+		return nextWeekday(today, time.Monday, false), nil
+	case "next month":
+		return time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, time.UTC), nil
+	case "next year":
+		return time.Date(today.Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC), nil
+	case "end of week", "eow":
+		return nextWeekday(today, (WeekStart+6)%7, true), nil
+	case "end of month", "eom":
+		return time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, time.UTC), nil
+	case "end of year", "eoy":
+		return time.Date(today.Year(), time.December, 31, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	if wd, ok := weekdays[value]; ok {
+		return nextWeekday(today, wd, true), nil
+	}
+	if rest, ok := strings.CutPrefix(value, "next "); ok {
+		if wd, ok := weekdays[rest]; ok {
+			return nextWeekday(today, wd, false), nil
+		}
+	}
+
+	// Offsets: "+3d" or "in 3 days".
+	if rest, ok := strings.CutPrefix(value, "+"); ok && len(rest) > 1 {
+		if n, err := strconv.Atoi(rest[:len(rest)-1]); err == nil {
+			if d, ok := addOffset(today, n, rest[len(rest)-1:]); ok {
+				return d, nil
+			}
+		}
+	}
+	if rest, ok := strings.CutPrefix(value, "in "); ok {
+		if fields := strings.Fields(rest); len(fields) == 2 {
+			if n, err := strconv.Atoi(fields[0]); err == nil {
+				if d, ok := addOffset(today, n, fields[1]); ok {
+					return d, nil
+				}
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised date '%s' (use %s)", input, DateInputHelp)
+}
+
+// nextWeekday returns the next date falling on wd. If includeToday is set and
+// today is already wd, today is returned.
+func nextWeekday(today time.Time, wd time.Weekday, includeToday bool) time.Time {
+	days := (int(wd) - int(today.Weekday()) + 7) % 7
+	if days == 0 && !includeToday {
+		days = 7
+	}
+	return today.AddDate(0, 0, days)
+}
+
+// addOffset adds n units to date, where unit is d/day(s), w/week(s), m/month(s) or y/year(s).
+func addOffset(date time.Time, n int, unit string) (time.Time, bool) {
+	switch strings.TrimSuffix(unit, "s") {
+	case "d", "day":
+		return date.AddDate(0, 0, n), true
+	case "w", "week":
+		return date.AddDate(0, 0, 7*n), true
+	case "m", "month":
+		return date.AddDate(0, n, 0), true
+	case "y", "year":
+		return date.AddDate(n, 0, 0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,138 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.Local)
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2026-12-24", "2026-12-24"},
+		{"today", "2026-10-14"},
+		{"Tomorrow", "2026-10-15"},
+		{"yesterday", "2026-10-13"},
+		{"wed", "2026-10-14"},
+		{"fri", "2026-10-16"},
+		{"monday", "2026-10-19"},
+		{"next wed", "2026-10-21"},
+		{"next week", "2026-10-19"},
+		{"next month", "2026-11-01"},
+		{"next year", "2027-01-01"},
+		{"+3d", "2026-10-17"},
+		{"+2w", "2026-10-28"},
+		{"+1m", "2026-11-14"},
+		{"in 3 days", "2026-10-17"},
+		{"in  1   week", "2026-10-21"},
+		{"eow", "2026-10-16"},
+		{"end of month", "2026-10-31"},
+		{"eoy", "2026-12-31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDate(tt.input, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Format("2006-01-02") != tt.want || got.Location() != time.UTC || got.Hour() != 0 {
+				t.Errorf("ParseDate(%q) = %s, want %s at midnight UTC", tt.input, got, tt.want)
+			}
+		})
+	}
+	for _, input := range []string{"", "someday", "+3x", "in three days", "2026-13-01"} {
+		if _, err := ParseDate(input, now); err == nil {
+			t.Errorf("ParseDate(%q) succeeded", input)
+		}
+	}
+}
+
+func TestParseDateEndOfWeek(t *testing.T) {
+	defer func(start time.Weekday) { WeekStart = start }(WeekStart)
+	now := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC) // a Wednesday
+	tests := []struct {
+		start time.Weekday
+		want  string
+	}{
+		{time.Saturday, "2026-10-16"}, // Friday
+		{time.Monday, "2026-10-18"},   // Sunday
+		{time.Sunday, "2026-10-17"},   // Saturday
+		{time.Thursday, "2026-10-14"}, // today
+	}
+	for _, tt := range tests {
+		WeekStart = tt.start
+		got, err := ParseDate("eow", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Format("2006-01-02") != tt.want {
+			t.Errorf("eow with the week starting on %s = %s, want %s", tt.start, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
+
+func TestNormalizeDue(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"2026-03-13", "2026-03-13"},
+		{"2026-03-13T14:00Z", "2026-03-13T14:00Z"},
+		{"today", today},
+		{"today 9:30", today + "T09:30"},
+		{"today 14:00 UTC", today + "T14:00Z"},
+		{"2026-03-13 17:00 +0200", "2026-03-13T17:00+02:00"},
+		{"2026-03-13 17:00 +02:00", "2026-03-13T17:00+02:00"},
+		{"2026-03-13 08:15 Europe/Berlin", "2026-03-13T08:15@Europe/Berlin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := NormalizeDue(tt.input)
+			if err != nil {
+				if strings.Contains(err.Error(), "unknown time zone") {
+					t.Skip("no time zone database:", err)
+				}
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeDue(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+	for _, input := range []string{"someday", "today UTC", "today 25:00", "today 14:00 Mars/Base"} {
+		if _, err := NormalizeDue(input); err == nil {
+			t.Errorf("NormalizeDue(%q) succeeded", input)
+		}
+	}
+}
+
+func TestParseDueTag(t *testing.T) {
+	tests := []struct {
+		value   string
+		hasTime bool
+		zone    string
+		want    string
+	}{
+		{"2026-03-13", false, "", "2026-03-13T00:00:00Z"},
+		{"2026-03-13T14:00Z", true, "UTC", "2026-03-13T14:00:00Z"},
+		{"2026-03-13T14:00+02:00", true, "+02:00", "2026-03-13T14:00:00+02:00"},
+	}
+	for _, tt := range tests {
+		d, hasTime, zone, err := parseDueTag(tt.value)
+		if err != nil {
+			t.Fatalf("parseDueTag(%q): %v", tt.value, err)
+		}
+		if hasTime != tt.hasTime || zone != tt.zone || d.Format(time.RFC3339) != tt.want {
+			t.Errorf("parseDueTag(%q) = %s, %v, %q", tt.value, d.Format(time.RFC3339), hasTime, zone)
+		}
+		if got := formatDueTag(Todo{DueDate: d, DueHasTime: hasTime, DueZone: zone}); got != tt.value {
+			t.Errorf("formatDueTag of %q = %q", tt.value, got)
+		}
+	}
+}
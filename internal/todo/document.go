@@ -0,0 +1,423 @@
+package todo
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Document is a todo file parsed line by line. Besides the tasks it keeps every
+// other line (headings, blank lines, free text) so that saving rewrites only the
+// task lines that actually changed and leaves the rest of the file untouched.
+type Document struct {
+	lines []docLine
+}
+
+// docLine is a single line of a Document. A task line also owns the indented
+// note lines that follow it.
+type docLine struct {
+	raw     string // the line exactly as read, without the trailing newline
+	noteRaw []string
+	isTask  bool
+	todo    Todo
+	dirty   bool // true if the task changed and the line must be rewritten
+}
+
+// LoadDocument reads and parses the todo file at filename.
+func LoadDocument(filename string) (*Document, error) {
+	content, err := ReadFileContent(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDocument(content), nil
+}
+
+// ParseDocument parses the content of a todo file. Completed tasks without a
+// "#completed" tag and tasks without a unique "#id" tag are fixed up and marked
+// as modified.
+func ParseDocument(content string) *Document {
+	doc := &Document{}
+	if content == "" {
+		return doc
+	}
+
+	noteOwner := -1 // index of the task whose notes may continue on the next line
+	for _, line := range strings.Split(content, "\n") {
+		dl := docLine{raw: line}
+		trimmed := strings.TrimSpace(line)
+
+		// Lines indented below a task that are not checkboxes are the task's notes.
+		if noteOwner >= 0 && trimmed != "" && !strings.HasPrefix(trimmed, "- [") &&
+			indentWidth(line) > indentWidth(doc.lines[noteOwner].raw) {
+			owner := &doc.lines[noteOwner]
+			owner.noteRaw = append(owner.noteRaw, line)
+			owner.todo.Notes = append(owner.todo.Notes, trimmed)
+			continue
+		}
+		noteOwner = -1
+
+		// Blank lines, headings, comments and free text are kept as they are;
+		// only malformed checkbox lines are reported.
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			t, err := parseTodo(trimmed)
+			if err != nil {
+				if strings.HasPrefix(trimmed, "- [") {
+					fmt.Fprintf(os.Stderr, "Skipping invalid task line: %s\n", trimmed)
+				}
+			} else {
+				dl.isTask = true
+				dl.todo = t
+				// For completed tasks, check if the original line is missing the "#completed" tag.
+				if !t.CompletedDate.IsZero() && !strings.Contains(line, "#completed:") {
+					dl.dirty = true
+				}
+				noteOwner = len(doc.lines)
+			}
+		}
+		doc.lines = append(doc.lines, dl)
+	}
+
+	// Backfill IDs for tasks that have none, and replace duplicated IDs
+	// (e.g. from a copied line) so every task can be addressed unambiguously.
+	ids := doc.ids()
+	seen := make(map[string]bool)
+	for i := range doc.lines {
+		dl := &doc.lines[i]
+		if !dl.isTask {
+			continue
+		}
+		if dl.todo.ID == "" || seen[dl.todo.ID] {
+			dl.todo.ID = newTodoID(ids)
+			dl.dirty = true
+		}
+		seen[dl.todo.ID] = true
+	}
+	return doc
+}
+
+// Todos returns the tasks of the document in file order. ParentID and Depth
+// are derived from the indentation of the checklist items.
+func (d *Document) Todos() []Todo {
+	type level struct {
+		width int
+		id    string
+	}
+	var stack []level
+	var todos []Todo
+	for _, dl := range d.lines {
+		width := indentWidth(dl.raw)
+		if !dl.isTask {
+			// Unindented text such as a heading ends the current list.
+			if strings.TrimSpace(dl.raw) != "" && width == 0 {
+				stack = nil
+			}
+			continue
+		}
+		for len(stack) > 0 && stack[len(stack)-1].width >= width {
+			stack = stack[:len(stack)-1]
+		}
+		t := dl.todo
+		t.Depth = len(stack)
+		t.ParentID = ""
+		if len(stack) > 0 {
+			t.ParentID = stack[len(stack)-1].id
+		}
+		stack = append(stack, level{width: width, id: t.ID})
+		todos = append(todos, t)
+	}
+	return todos
+}
+
+// Get returns the task with the given ID.
+func (d *Document) Get(id string) (Todo, bool) {
+	todos := d.Todos()
+	if i := FindTodoByID(todos, id); i >= 0 {
+		return todos[i], true
+	}
+	return Todo{}, false
+}
+
+// Update replaces the task that has the same ID as t. The line is only
+// rewritten if the task actually changed.
+func (d *Document) Update(t Todo) error {
+	i := d.lineOf(t.ID)
+	if i < 0 {
+		return fmt.Errorf("task '%s' not found", t.ID)
+	}
+	if !sameTask(d.lines[i].todo, t) {
+		d.lines[i].todo = t
+		d.lines[i].dirty = true
+	}
+	return nil
+}
+
+// Delete removes the line of the task with the given ID along with the
+// lines of all of its subtasks.
+func (d *Document) Delete(id string) error {
+	i := d.lineOf(id)
+	if i < 0 {
+		return fmt.Errorf("task '%s' not found", id)
+	}
+	remove := map[string]bool{d.lines[i].todo.ID: true}
+	for _, t := range d.Todos() {
+		if remove[t.ParentID] {
+			remove[t.ID] = true
+		}
+	}
+
+	var lines []docLine
+	for _, dl := range d.lines {
+		if dl.isTask && remove[dl.todo.ID] {
+			continue
+		}
+		lines = append(lines, dl)
+	}
+	d.lines = lines
+	return nil
+}
+
+// AddLine parses a task line and inserts it after the "# todo" header (or at
+// the top of the file if there is none). A task without an ID is given one.
+func (d *Document) AddLine(line string) (Todo, error) {
+	t, err := parseTodo(strings.TrimSpace(line))
+	if err != nil {
+		return t, err
+	}
+	dl := docLine{raw: line, isTask: true, todo: t}
+	if t.ID == "" || d.lineOf(t.ID) >= 0 {
+		dl.todo.ID = newTodoID(d.ids())
+		dl.dirty = true
+	}
+
+	insertIndex := 0
+	for i, l := range d.lines {
+		if strings.HasPrefix(strings.TrimSpace(l.raw), "# todo") {
+			insertIndex = i + 1
+			break
+		}
+	}
+	for insertIndex < len(d.lines) && strings.TrimSpace(d.lines[insertIndex].raw) == "" {
+		insertIndex++
+	}
+	d.lines = append(d.lines[:insertIndex], append([]docLine{dl}, d.lines[insertIndex:]...)...)
+	return dl.todo, nil
+}
+
+// InsertAfter inserts t on a new line below the task with the given ID and
+// its subtasks, using the same indentation, so t becomes its next sibling.
+func (d *Document) InsertAfter(id string, t Todo) error {
+	i := d.lineOf(id)
+	if i < 0 {
+		return fmt.Errorf("task '%s' not found", id)
+	}
+	dl := docLine{raw: leadingSpace(d.lines[i].raw), isTask: true, todo: t, dirty: true}
+
+	subtree := map[string]bool{d.lines[i].todo.ID: true}
+	for _, sub := range d.Todos() {
+		if subtree[sub.ParentID] {
+			subtree[sub.ID] = true
+		}
+	}
+	last := i
+	for j := i + 1; j < len(d.lines); j++ {
+		if d.lines[j].isTask && subtree[d.lines[j].todo.ID] {
+			last = j
+		}
+	}
+	d.lines = append(d.lines[:last+1], append([]docLine{dl}, d.lines[last+1:]...)...)
+	return nil
+}
+
+// Replace makes the tasks of the document match todos: tasks are matched by ID,
+// changed ones are updated in place, ones missing from todos are removed and
+// the remaining ones are appended after the last task (or at the end of the
+// document if it has no tasks yet).
+func (d *Document) Replace(todos []Todo) {
+	ids := d.ids()
+	wanted := make(map[string]Todo)
+	var added []Todo
+	for _, t := range todos {
+		if t.ID == "" {
+			t.ID = newTodoID(ids)
+			added = append(added, t)
+			continue
+		}
+		ids[t.ID] = true
+		if d.lineOf(t.ID) < 0 {
+			added = append(added, t)
+			continue
+		}
+		wanted[t.ID] = t
+	}
+
+	var lines []docLine
+	lastTask := -1
+	for _, dl := range d.lines {
+		if dl.isTask {
+			t, ok := wanted[dl.todo.ID]
+			if !ok {
+				continue
+			}
+			if !sameTask(dl.todo, t) {
+				dl.todo = t
+				dl.dirty = true
+			}
+			lastTask = len(lines)
+		}
+		lines = append(lines, dl)
+	}
+
+	var newLines []docLine
+	for _, t := range added {
+		indent := strings.Repeat("  ", t.Depth)
+		newLines = append(newLines, docLine{raw: indent, isTask: true, todo: t, dirty: true})
+	}
+	insertIndex := lastTask + 1
+	if lastTask < 0 {
+		insertIndex = len(lines)
+	}
+	d.lines = append(lines[:insertIndex], append(newLines, lines[insertIndex:]...)...)
+}
+
+// Modified reports whether any task line has to be rewritten.
+func (d *Document) Modified() bool {
+	for _, dl := range d.lines {
+		if dl.dirty {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the document. Unchanged lines are returned exactly as read.
+func (d *Document) String() string {
+	lines := make([]string, len(d.lines))
+	for i, dl := range d.lines {
+		lines[i] = dl.render()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Save writes the document to filename.
+func (d *Document) Save(filename string) error {
+	if err := WriteFileContent(filename, d.String()); err != nil {
+		return err
+	}
+	for i := range d.lines {
+		if d.lines[i].dirty {
+			rendered := strings.Split(d.lines[i].render(), "\n")
+			d.lines[i].raw = rendered[0]
+			d.lines[i].noteRaw = rendered[1:]
+			d.lines[i].dirty = false
+		}
+	}
+	return nil
+}
+
+// render returns the text of the line and its notes, rewriting the task if it
+// changed while keeping the original indentation and line ending. Notes are
+// written back as read unless they changed.
+func (dl docLine) render() string {
+	if !dl.dirty {
+		return strings.Join(append([]string{dl.raw}, dl.noteRaw...), "\n")
+	}
+	indent := leadingSpace(dl.raw)
+	eol := ""
+	if strings.HasSuffix(dl.raw, "\r") {
+		eol = "\r"
+	}
+	lines := []string{indent + formatTodo(dl.todo) + eol}
+	return strings.Join(append(lines, dl.renderNotes(indent+"  ", eol)...), "\n")
+}
+
+// renderNotes returns the note lines of a task. A note that was already in the
+// file keeps its line, and so its indentation; a new note is indented like the
+// note before it, or by defaultIndent if it comes first.
+func (dl docLine) renderNotes(defaultIndent string, eol string) []string {
+	if sameNotes(dl.noteRaw, dl.todo.Notes) {
+		return dl.noteRaw
+	}
+	used := make([]bool, len(dl.noteRaw))
+	indent := defaultIndent
+	var lines []string
+	for _, note := range dl.todo.Notes {
+		line := ""
+		for i, raw := range dl.noteRaw {
+			if !used[i] && strings.TrimSpace(raw) == note {
+				used[i] = true
+				line = raw
+				break
+			}
+		}
+		if line == "" {
+			line = indent + note + eol
+		}
+		indent = leadingSpace(line)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// sameNotes reports whether the note lines raw hold exactly notes.
+func sameNotes(raw []string, notes []string) bool {
+	if len(raw) != len(notes) {
+		return false
+	}
+	for i, line := range raw {
+		if strings.TrimSpace(line) != notes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// leadingSpace returns the spaces and tabs line starts with.
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// sameTask reports whether a and b would be written to the file identically.
+func sameTask(a, b Todo) bool {
+	return formatTodo(a) == formatTodo(b) && strings.Join(a.Notes, "\n") == strings.Join(b.Notes, "\n")
+}
+
+// lineOf returns the line index of the task with the given ID, or -1.
+func (d *Document) lineOf(id string) int {
+	id = normalizeID(id)
+	if id == "" {
+		return -1
+	}
+	for i, dl := range d.lines {
+		if dl.isTask && dl.todo.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ids returns the set of task IDs used in the document.
+func (d *Document) ids() map[string]bool {
+	ids := make(map[string]bool)
+	for _, dl := range d.lines {
+		if dl.isTask && dl.todo.ID != "" {
+			ids[dl.todo.ID] = true
+		}
+	}
+	return ids
+}
+
+// indentWidth returns the width of the leading whitespace of line, counting a tab as four spaces.
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
@@ -0,0 +1,141 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", ""},
+		{"headings and text", "# todo\n\nSome free text.\n\n## Later\n"},
+		{"tasks with ids", "# todo\n\n- [ ] first #id:aaaa\n- [~] second #p:2 #id:bbbb\n"},
+		{"unknown tags", "- [ ] task #color:red #id:aaaa #foo:bar\n"},
+		{"subtasks", "- [ ] parent #id:aaaa\n  - [ ] child #id:bbbb\n    - [ ] grandchild #id:cccc\n"},
+		{"notes", "- [ ] task #id:aaaa\n    nested note\n\ttab note\n  plain note\n"},
+		{"crlf", "- [ ] task #id:aaaa\r\n  note\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := ParseDocument(tt.content)
+			if doc.Modified() {
+				t.Fatalf("document is modified after parsing")
+			}
+			if got := doc.String(); got != tt.content {
+				t.Errorf("String() = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
+
+func TestDocumentBackfillsIDs(t *testing.T) {
+	content := "# todo\n- [ ] no id\n- [ ] dup #id:aaaa\n- [ ] dup again #id:aaaa\n"
+	doc := ParseDocument(content)
+	if !doc.Modified() {
+		t.Fatal("document with missing and duplicate IDs is not modified")
+	}
+	seen := make(map[string]bool)
+	for _, td := range doc.Todos() {
+		if td.ID == "" {
+			t.Errorf("task %q has no ID", td.Description)
+		}
+		if seen[td.ID] {
+			t.Errorf("ID %s is used twice", td.ID)
+		}
+		seen[td.ID] = true
+	}
+	lines := strings.Split(doc.String(), "\n")
+	if lines[0] != "# todo" || !strings.HasSuffix(lines[2], "#id:aaaa") {
+		t.Errorf("unexpected rewrite:\n%s", doc.String())
+	}
+}
+
+func TestDocumentRewriteKeepsNotes(t *testing.T) {
+	notes := "    nested note\n\ttab note\n  plain note\n"
+	tests := []struct {
+		name    string
+		content string
+		edit    func(td *Todo)
+		want    string
+	}{
+		{
+			name:    "id backfill",
+			content: "- [ ] task\n" + notes,
+			want:    "- [ ] task #id:",
+		},
+		{
+			name:    "status change",
+			content: "- [ ] task #id:aaaa\n" + notes,
+			edit:    func(td *Todo) { td.Ongoing = true },
+			want:    "- [~] task #id:aaaa",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := ParseDocument(tt.content)
+			if tt.edit != nil {
+				td := doc.Todos()[0]
+				tt.edit(&td)
+				if err := doc.Update(td); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := doc.String()
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("task line of %q does not start with %q", got, tt.want)
+			}
+			if !strings.HasSuffix(got, "\n"+notes) {
+				t.Errorf("notes were not kept as written:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestDocumentChangedNotesKeepIndentation(t *testing.T) {
+	doc := ParseDocument("- [ ] task #id:aaaa\n  first\n      nested\n  last\n")
+	td := doc.Todos()[0]
+	td.Notes = []string{"first", "nested", "added", "new last"}
+	if err := doc.Update(td); err != nil {
+		t.Fatal(err)
+	}
+	want := "- [ ] task #id:aaaa\n  first\n      nested\n      added\n      new last\n"
+	if got := doc.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDocumentTodosDerivesParents(t *testing.T) {
+	doc := ParseDocument("- [ ] a #id:aaaa\n  - [ ] b #id:bbbb\n    - [ ] c #id:cccc\n  - [ ] d #id:dddd\n# heading\n- [ ] e #id:eeee\n")
+	want := []struct {
+		id, parent string
+		depth      int
+	}{
+		{"aaaa", "", 0},
+		{"bbbb", "aaaa", 1},
+		{"cccc", "bbbb", 2},
+		{"dddd", "aaaa", 1},
+		{"eeee", "", 0},
+	}
+	todos := doc.Todos()
+	if len(todos) != len(want) {
+		t.Fatalf("got %d tasks, want %d", len(todos), len(want))
+	}
+	for i, w := range want {
+		if todos[i].ID != w.id || todos[i].ParentID != w.parent || todos[i].Depth != w.depth {
+			t.Errorf("task %d = {%s %s %d}, want {%s %s %d}", i, todos[i].ID, todos[i].ParentID, todos[i].Depth, w.id, w.parent, w.depth)
+		}
+	}
+}
+
+func TestDocumentDeleteRemovesSubtasks(t *testing.T) {
+	doc := ParseDocument("- [ ] a #id:aaaa\n  - [ ] b #id:bbbb\n    note\n- [ ] c #id:cccc\n")
+	if err := doc.Delete("aaaa"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.String(), "- [ ] c #id:cccc\n"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
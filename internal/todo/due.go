@@ -0,0 +1,164 @@
+package todo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DueSoonWindow is how far ahead a deadline counts as due soon.
+const DueSoonWindow = 48 * time.Hour
+
+// offsetRegex matches a numeric UTC offset such as "+02:00" or "-0500".
+var offsetRegex = regexp.MustCompile(`^[+-]\d{2}:?\d{2}$`)
+
+// clockRegex matches a time of day such as "9:30" or "14:00".
+var clockRegex = regexp.MustCompile(`^\d{1,2}:\d{2}$`)
+
+// parseDueTag parses the value of a "#due" tag. The value is either a date
+// ("2025-03-13") or a date and time, optionally followed by a zone:
+//
+//	2025-03-13T14:00                 floating, interpreted in local time
+//	2025-03-13T14:00Z                UTC
+//	2025-03-13T14:00+02:00           a fixed offset
+//	2025-03-13T14:00@Europe/Berlin   an IANA time zone
+//
+// It returns the due time, whether a time of day was given and the zone as written.
+func parseDueTag(value string) (time.Time, bool, string, error) {
+	if d, err := time.Parse("2006-01-02", value); err == nil {
+		return d, false, "", nil
+	}
+
+	if clock, zone, ok := strings.Cut(value, "@"); ok {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return time.Time{}, false, "", fmt.Errorf("unknown time zone '%s'", zone)
+		}
+		d, err := time.ParseInLocation("2006-01-02T15:04", clock, loc)
+		if err != nil {
+			return time.Time{}, false, "", fmt.Errorf("invalid due_date format")
+		}
+		return d, true, loc.String(), nil
+	}
+	if d, err := time.Parse("2006-01-02T15:04Z07:00", value); err == nil {
+		zone := value[len("2006-01-02T15:04"):]
+		if zone == "Z" {
+			zone = "UTC"
+		}
+		return d, true, zone, nil
+	}
+	if d, err := time.ParseInLocation("2006-01-02T15:04", value, time.Local); err == nil {
+		return d, true, "", nil
+	}
+	return time.Time{}, false, "", fmt.Errorf("invalid due_date format")
+}
+
+// formatDueTag returns the "#due" tag value of a task.
+func formatDueTag(t Todo) string {
+	if !t.DueHasTime {
+		return t.DueDate.Format("2006-01-02")
+	}
+	value := t.DueDate.Format("2006-01-02T15:04")
+	switch {
+	case t.DueZone == "":
+		return value
+	case t.DueZone == "UTC":
+		return value + "Z"
+	case offsetRegex.MatchString(t.DueZone):
+		return value + t.DueZone
+	default:
+		return value + "@" + t.DueZone
+	}
+}
+
+// FormatDue returns the due date of a task for display, including the time
+// and zone if it has them, or an empty string if it has no due date.
+func FormatDue(t Todo) string {
+	if t.DueDate.IsZero() {
+		return ""
+	}
+	if !t.DueHasTime {
+		return t.DueDate.Format("2006-01-02")
+	}
+	return strings.TrimSpace(t.DueDate.Format("2006-01-02 15:04") + " " + t.DueZone)
+}
+
+// DueAt returns the moment a task becomes overdue: its due time if it has one,
+// otherwise the end of its due day in local time.
+func (t Todo) DueAt() time.Time {
+	if t.DueHasTime {
+		return t.DueDate
+	}
+	return time.Date(t.DueDate.Year(), t.DueDate.Month(), t.DueDate.Day(), 23, 59, 59, 0, time.Local)
+}
+
+// IsOverdue reports whether an unfinished task is past its deadline at now.
+func (t Todo) IsOverdue(now time.Time) bool {
+	return t.CompletedDate.IsZero() && !t.DueDate.IsZero() && now.After(t.DueAt())
+}
+
+// IsDueSoon reports whether an unfinished task is due within DueSoonWindow of now.
+func (t Todo) IsDueSoon(now time.Time) bool {
+	return t.CompletedDate.IsZero() && !t.DueDate.IsZero() && !t.IsOverdue(now) &&
+		t.DueAt().Sub(now) <= DueSoonWindow
+}
+
+// NormalizeDue converts due date input into the value of a "#due" tag. The
+// input is a date accepted by ParseDate, optionally followed by a time of day
+// and a zone, e.g. "thu 14:00 UTC", "tomorrow 9:30 Europe/Berlin" or
+// "2025-03-13 17:00 +02:00". A tag value such as "2025-03-13T14:00Z" is also
+// accepted. An empty input stays empty.
+func NormalizeDue(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", nil
+	}
+	if _, _, _, err := parseDueTag(input); err == nil {
+		return input, nil
+	}
+
+	fields := strings.Fields(input)
+	zone := ""
+	if n := len(fields); n > 1 {
+		last := fields[n-1]
+		switch {
+		case strings.EqualFold(last, "utc"), strings.EqualFold(last, "z"), strings.EqualFold(last, "gmt"):
+			zone, fields = "Z", fields[:n-1]
+		case offsetRegex.MatchString(last):
+			if !strings.Contains(last, ":") {
+				last = last[:3] + ":" + last[3:]
+			}
+			zone, fields = last, fields[:n-1]
+		case strings.Contains(last, "/"):
+			if _, err := time.LoadLocation(last); err != nil {
+				return "", fmt.Errorf("unknown time zone '%s'", last)
+			}
+			zone, fields = "@"+last, fields[:n-1]
+		}
+	}
+
+	clock := ""
+	if n := len(fields); n > 1 && clockRegex.MatchString(fields[n-1]) {
+		clock, fields = fields[n-1], fields[:n-1]
+		if len(clock) == 4 {
+			clock = "0" + clock
+		}
+	}
+	if clock == "" && zone != "" {
+		return "", fmt.Errorf("a time zone needs a time of day, e.g. '%s 14:00 UTC'", strings.Join(fields, " "))
+	}
+
+	d, err := ParseDate(strings.Join(fields, " "), time.Now())
+	if err != nil {
+		return "", err
+	}
+	value := d.Format("2006-01-02")
+	if clock != "" {
+		value += "T" + clock + zone
+	}
+	if _, _, _, err := parseDueTag(value); err != nil {
+		return "", fmt.Errorf("invalid time '%s'", clock)
+	}
+	return value, nil
+}
@@ -0,0 +1,85 @@
+package todo
+
+import (
+	"strings"
+	"time"
+)
+
+// FilterTodos returns the todos matching every term of query. Terms are
+// separated by spaces and may be:
+//
+//	#label       a bare label
+//	@context     a context marker
+//	key:value    a generic tag, or the project/workspace name
+//	due:overdue  unfinished tasks past their deadline
+//	due:soon     unfinished tasks due within DueSoonWindow
+//	due:none     tasks without a due date
+//	due:<date>   tasks due by the end of a date accepted by ParseDate, e.g. due:fri
+//	text         a case-insensitive substring of the description
+func FilterTodos(todos []Todo, query string) []Todo {
+	terms := strings.Fields(query)
+	var filtered []Todo
+	for _, t := range todos {
+		if matchesAll(t, terms) {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+// matchesAll reports whether the task matches every filter term.
+func matchesAll(t Todo, terms []string) bool {
+	for _, term := range terms {
+		if !matchesTerm(t, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesTerm reports whether the task matches a single filter term.
+func matchesTerm(t Todo, term string) bool {
+	if key, value, ok := strings.Cut(strings.TrimPrefix(term, "#"), ":"); ok && key != "" {
+		switch strings.ToLower(key) {
+		case "project":
+			return strings.EqualFold(t.ProjectName, value)
+		case "workspace":
+			return strings.EqualFold(t.WorkspaceName, value)
+		case "due":
+			return matchesDue(t, value, time.Now())
+		}
+		v, found := t.TagValue(key)
+		return found && (value == "" || strings.EqualFold(v, value))
+	}
+	if strings.HasPrefix(term, "#") {
+		return t.HasLabel(strings.TrimPrefix(term, "#"))
+	}
+	if strings.HasPrefix(term, "@") {
+		return t.HasContext(strings.TrimPrefix(term, "@"))
+	}
+	return strings.Contains(strings.ToLower(t.Description), strings.ToLower(term))
+}
+
+// matchesDue reports whether the task's deadline matches a "due:" filter value.
+func matchesDue(t Todo, value string, now time.Time) bool {
+	switch strings.ToLower(value) {
+	case "overdue":
+		return t.IsOverdue(now)
+	case "soon":
+		return t.IsDueSoon(now)
+	case "none":
+		return t.DueDate.IsZero()
+	}
+	if t.DueDate.IsZero() {
+		return false
+	}
+	// Dates with spaces can't be used in a filter term, so allow dashes: due:end-of-month.
+	d, err := ParseDate(strings.ReplaceAll(value, "-", " "), now)
+	if err != nil {
+		if d, err = ParseDate(value, now); err != nil {
+			return false
+		}
+	}
+	endOfDay := time.Date(d.Year(), d.Month(), d.Day(), 23, 59, 59, 0, time.Local)
+	return !t.DueAt().After(endOfDay)
+}
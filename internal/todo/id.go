@@ -0,0 +1,106 @@
+package todo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// idLength is the number of hex characters in a generated task ID.
+const idLength = 6
+
+// newTodoID returns a short random identifier that is not already in use.
+func newTodoID(existing map[string]bool) string {
+	for {
+		var id string
+		b := make([]byte, idLength/2)
+		if _, err := rand.Read(b); err == nil {
+			id = hex.EncodeToString(b)
+		} else {
+			// Fall back to a time based ID if the random source is unavailable.
+			id = strconv.FormatInt(time.Now().UnixNano(), 16)
+			id = id[len(id)-idLength:]
+		}
+		if !existing[id] {
+			existing[id] = true
+			return id
+		}
+	}
+}
+
+// FindTodoByID returns the position of the task with the given ID, or -1 if it is not found.
+func FindTodoByID(todos []Todo, id string) int {
+	id = normalizeID(id)
+	for i, t := range todos {
+		if t.ID != "" && t.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ResolveTodoID turns user input into a task ID. The input may be either the
+// number shown next to a task in the listing or the task's ID itself.
+func ResolveTodoID(todos []Todo, input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("no task given")
+	}
+	if isTaskNumber(input) {
+		index, _ := strconv.Atoi(input)
+		if index < 1 || index > len(todos) {
+			return "", fmt.Errorf("invalid task number: %d", index)
+		}
+		if todos[index-1].ID == "" {
+			return "", fmt.Errorf("task %d has no ID", index)
+		}
+		return todos[index-1].ID, nil
+	}
+	if i := FindTodoByID(todos, input); i >= 0 {
+		return todos[i].ID, nil
+	}
+	return "", fmt.Errorf("no task with ID '%s'", input)
+}
+
+// ResolveListedTodoID is ResolveTodoID for a screen listing only some of the
+// tasks: a number refers to the tasks of listed in the order they are shown,
+// while an ID may name any task of todos.
+func ResolveListedTodoID(listed []Todo, todos []Todo, input string) (string, error) {
+	if isTaskNumber(strings.TrimSpace(input)) {
+		return ResolveTodoID(listed, input)
+	}
+	return ResolveTodoID(todos, input)
+}
+
+// isTaskNumber reports whether input is a row number rather than an ID. Row
+// numbers are always shorter than generated IDs, which may be all digits.
+func isTaskNumber(input string) bool {
+	_, err := strconv.Atoi(input)
+	return err == nil && len(input) < idLength
+}
+
+// TaskNumbers returns the numbers and IDs the tasks of todos can be given by
+// to ResolveTodoID, for completion.
+func TaskNumbers(todos []Todo) []string {
+	var refs []string
+	for i := range todos {
+		refs = append(refs, strconv.Itoa(i+1))
+	}
+	for _, t := range todos {
+		if t.ID != "" {
+			refs = append(refs, t.ID)
+		}
+	}
+	return refs
+}
+
+// normalizeID strips an optional "#id:" or "#" prefix and lowercases the ID.
+func normalizeID(id string) string {
+	id = strings.TrimSpace(id)
+	id = strings.TrimPrefix(id, "#id:")
+	id = strings.TrimPrefix(id, "#")
+	return strings.ToLower(id)
+}
@@ -0,0 +1,95 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveTodoID(t *testing.T) {
+	todos := []Todo{
+		{ID: "a1b2c3", Description: "first"},
+		{ID: "123456", Description: "all digits"},
+		{Description: "no id"},
+	}
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{input: "1", want: "a1b2c3"},
+		{input: " 2 ", want: "123456"},
+		{input: "a1b2c3", want: "a1b2c3"},
+		{input: "A1B2C3", want: "a1b2c3"},
+		{input: "#id:a1b2c3", want: "a1b2c3"},
+		{input: "#a1b2c3", want: "a1b2c3"},
+		// An all-digit ID is as long as a generated ID, so it is not a row number.
+		{input: "123456", want: "123456"},
+		{input: "", wantErr: "no task given"},
+		{input: "0", wantErr: "invalid task number"},
+		{input: "4", wantErr: "invalid task number"},
+		{input: "3", wantErr: "has no ID"},
+		{input: "ffffff", wantErr: "no task with ID"},
+		{input: "654321", wantErr: "no task with ID"},
+	}
+	for _, tt := range tests {
+		got, err := ResolveTodoID(todos, tt.input)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveTodoID(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveTodoID(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewTodoID(t *testing.T) {
+	existing := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := newTodoID(existing)
+		if len(id) != idLength || strings.Trim(id, "0123456789abcdef") != "" {
+			t.Fatalf("newTodoID() = %q, want %d hex characters", id, idLength)
+		}
+	}
+	// Every ID is new and has been added to existing.
+	if len(existing) != 100 {
+		t.Errorf("newTodoID returned %d distinct IDs, want 100", len(existing))
+	}
+}
+
+func TestResolveListedTodoID(t *testing.T) {
+	todos := []Todo{
+		{ID: "aaaaaa", Description: "waiting"},
+		{ID: "bbbbbb", Description: "ongoing", Ongoing: true},
+		{ID: "cccccc", Description: "also ongoing", Ongoing: true},
+	}
+	listed := FilterTodosByOngoing(todos)
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		// Numbers are those shown next to the listed tasks.
+		{input: "1", want: "bbbbbb"},
+		{input: "2", want: "cccccc"},
+		{input: "3", wantErr: "invalid task number"},
+		// IDs name any task, listed or not.
+		{input: "aaaaaa", want: "aaaaaa"},
+		{input: "#id:cccccc", want: "cccccc"},
+		{input: "dddddd", wantErr: "no task with ID"},
+	}
+	for _, tt := range tests {
+		got, err := ResolveListedTodoID(listed, todos, tt.input)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveListedTodoID(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveListedTodoID(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package todo
+
+import (
+	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/output"
+)
+
+// todoFields are the fields of every task in TodoListing, in order.
+var todoFields = []string{
+	"id", "description", "status", "blocked", "priority", "due", "due_zone", "created", "completed",
+	"project", "workspace", "parent_id", "recur", "labels", "contexts", "tags", "blocked_by", "notes",
+}
+
+// TodoListing returns todos as a listing for output.Write. The status is
+// "open", "ongoing" or "complete". Dates are written as YYYY-MM-DD and a due
+// time as RFC 3339, with due_zone naming the zone it was given in ("UTC", an
+// offset or an IANA name, nil for local time). Unset values are nil, as is a
+// missing priority.
+func TodoListing(todos []Todo) *output.Listing {
+	l := output.NewListing(todoFields...)
+	for _, t := range todos {
+		l.Add(todoValues(t)...)
+	}
+	return l
+}
+
+// ArchiveListing returns archived tasks as a listing for output.Write, with
+// the fields of TodoListing.
+func ArchiveListing(archived []ArchivedTodo) *output.Listing {
+	l := output.NewListing(todoFields...)
+	for _, a := range archived {
+		l.Add(todoValues(a.Todo)...)
+	}
+	return l
+}
+
+// Listing returns the tasks of a weekly review as a listing for
+// output.Write: the fields of TodoListing preceded by the section of the
+// review the task is in, "completed", "unfinished", "overdue" or "due_soon".
+// Overdue tasks and tasks due soon are listed as unfinished as well.
+func (r WeeklyReview) Listing() *output.Listing {
+	l := output.NewListing(append([]string{"section"}, todoFields...)...)
+	sections := []struct {
+		name  string
+		todos []Todo
+	}{
+		{"completed", r.Completed},
+		{"unfinished", r.Unfinished},
+		{"overdue", r.Overdue},
+		{"due_soon", r.DueSoon},
+	}
+	for _, s := range sections {
+		for _, t := range s.todos {
+			l.Add(append([]interface{}{s.name}, todoValues(t)...)...)
+		}
+	}
+	return l
+}
+
+// todoValues returns the values of todoFields for t.
+func todoValues(t Todo) []interface{} {
+	status := "open"
+	switch {
+	case !t.CompletedDate.IsZero():
+		status = "complete"
+	case t.Ongoing:
+		status = "ongoing"
+	}
+	var priority, due, dueZone interface{}
+	if t.Priority != 0 {
+		priority = t.Priority
+	}
+	if !t.DueDate.IsZero() {
+		due = t.DueDate.Format("2006-01-02")
+		if t.DueHasTime {
+			due = t.DueDate.Format(time.RFC3339)
+		}
+	}
+	if t.DueZone != "" {
+		dueZone = t.DueZone
+	}
+	tags := make([]string, len(t.Tags))
+	for i, tag := range t.Tags {
+		tags[i] = tag.Key + ":" + tag.Value
+	}
+	return []interface{}{
+		t.ID, t.Description, status, t.Blocked, priority, due, dueZone, dateValue(t.CreatedDate), dateValue(t.CompletedDate),
+		t.ProjectName, t.WorkspaceName, t.ParentID, t.Recur, t.Labels, t.Contexts, tags, t.BlockedBy, t.Notes,
+	}
+}
+
+// dateValue returns d as YYYY-MM-DD, or nil if it is zero.
+func dateValue(d time.Time) interface{} {
+	if d.IsZero() {
+		return nil
+	}
+	return d.Format("2006-01-02")
+}
@@ -0,0 +1,46 @@
+package todo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTodoListingDue(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	tests := []struct {
+		name    string
+		todo    Todo
+		due     interface{}
+		dueZone interface{}
+	}{
+		{"no due date", Todo{}, nil, nil},
+		{"date only", Todo{DueDate: date("2026-10-16")}, "2026-10-16", nil},
+		{"utc", Todo{DueDate: time.Date(2026, 10, 16, 14, 0, 0, 0, time.UTC), DueHasTime: true, DueZone: "UTC"}, "2026-10-16T14:00:00Z", "UTC"},
+		{"offset", Todo{DueDate: time.Date(2026, 10, 16, 14, 0, 0, 0, time.FixedZone("", 2*3600)), DueHasTime: true, DueZone: "+02:00"}, "2026-10-16T14:00:00+02:00", "+02:00"},
+		{"named zone", Todo{DueDate: time.Date(2026, 1, 16, 9, 30, 0, 0, berlin), DueHasTime: true, DueZone: "Europe/Berlin"}, "2026-01-16T09:30:00+01:00", "Europe/Berlin"},
+	}
+	fields := TodoListing(nil).Fields
+	index := func(name string) int {
+		for i, f := range fields {
+			if f == name {
+				return i
+			}
+		}
+		t.Fatalf("no field %s", name)
+		return -1
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := TodoListing([]Todo{tt.todo}).Rows[0]
+			if got := row[index("due")]; got != tt.due {
+				t.Errorf("due = %v, want %v", got, tt.due)
+			}
+			if got := row[index("due_zone")]; got != tt.dueZone {
+				t.Errorf("due_zone = %v, want %v", got, tt.dueZone)
+			}
+		})
+	}
+}
@@ -3,86 +3,98 @@ package todo
 import (
 	"database/sql"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/config"
 )
 
-// InsertTodo inserts a single todo entry into the database.
+// InsertTodo inserts a single todo entry into the database as an archived task.
 func InsertTodo(db *sql.DB, t Todo) error {
 	query := `
-	INSERT INTO todos (description, completed_date, created_date, due_date, project_name, workspace_name)
-	VALUES (?, ?, ?, ?, ?, ?);
+	INSERT INTO todos (task_id, description, status, ongoing, priority, completed_date, created_date, due_date,
+		due, recur, series, parent_id, blocked_by, tags, notes, project_name, workspace_name, project_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ` + projectIDQuery + `);
 	`
-	// For date fields, if a zero value is present, we pass nil.
-	var completedDate interface{}
-	if !t.CompletedDate.IsZero() {
-		completedDate = t.CompletedDate
-	} else {
-		completedDate = nil
-	}
-
-	var dueDate interface{}
-	if !t.DueDate.IsZero() {
-		dueDate = t.DueDate
-	} else {
-		dueDate = nil
-	}
-
-	_, err := db.Exec(query,
-		t.Description,
-		completedDate,
-		t.CreatedDate,
-		dueDate,
-		t.ProjectName,
-		t.WorkspaceName,
-	)
+	args := append([]interface{}{t.ID}, todoColumnValues(t, statusArchived)...)
+	args = append(args, t.ProjectName, t.WorkspaceName, t.ProjectName, t.WorkspaceName)
+	_, err := db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("error inserting todo: %w", err)
 	}
 	return nil
 }
 
-func MigrateFinishedTodos(todoPath string, db *sql.DB) error {
+// MigrateFinishedTodos moves the finished tasks of the todo file at todoPath
+// that policy selects at now (see selectArchivable) into the archive in db and
+// returns them. Every other task stays in the file; a task that could not be
+// archived is kept too.
+func MigrateFinishedTodos(todoPath string, db *sql.DB, policy config.ArchivePolicy, now time.Time) ([]Todo, error) {
 	// Load all todos from the file.
 	todos, err := LoadAllTodos(todoPath)
 	if err != nil {
-		return fmt.Errorf("error loading todos: %w", err)
+		return nil, fmt.Errorf("error loading todos: %w", err)
+	}
+	selected := selectArchivable(todos, policy, now)
+	if len(selected) == 0 {
+		return nil, nil
 	}
 
-	var remainingTodos []Todo
-	var migratedCount int
-
-	// Calculate the cutoff date (7 days ago)
-	cutoffDate := time.Now().AddDate(0, 0, -7)
-
-	fmt.Println("Checking for completed todos older than 7 days...")
-
+	ids := make(map[string]bool)
 	for _, t := range todos {
-		fmt.Println("Checking:", t.Description)
+		ids[t.ID] = true
+	}
 
-		// Ensure CompletedDate is valid before checking
-		if t.CompletedDate.IsZero() {
-			fmt.Println("Skipping, no completion date set")
+	var remainingTodos, archivedTodos []Todo
+	for i, t := range todos {
+		if !selected[i] {
 			remainingTodos = append(remainingTodos, t)
 			continue
 		}
 
-		if t.CompletedDate.Before(cutoffDate) {
-			fmt.Println("completed")
-			fmt.Println("Migrating:", t.Description)
-			// add logic for insert to db
-			InsertTodo(db, t)
-			migratedCount++
+		// Untagged tasks are archived under the project owning the file.
+		archived := t
+		if archived.ProjectName == "" {
+			archived.ProjectName = filepath.Base(filepath.Dir(todoPath))
+		}
+		if archived.WorkspaceName == "" {
+			archived.WorkspaceName = filepath.Base(filepath.Dir(filepath.Dir(todoPath)))
 		}
-		// If any todos were migrated, update the todo file.
-		if migratedCount > 0 {
-			if err := SaveTodos(todoPath, remainingTodos); err != nil {
-				return fmt.Errorf("error saving todos: %w", err)
+		if err := InsertTodo(db, archived); err != nil {
+			fmt.Fprintln(os.Stderr, "Error archiving todo, keeping it in the file:", err)
+			remainingTodos = append(remainingTodos, t)
+			continue
+		}
+		archivedTodos = append(archivedTodos, t)
+
+		// Archiving the last occurrence of a recurring task must not end the series.
+		if t.Recur != "" && !hasOpenOccurrence(todos, seriesOf(t)) {
+			next, err := nextOccurrence(t, ids)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error scheduling next occurrence:", err)
+			} else {
+				todos = append(todos, next)
+				remainingTodos = append(remainingTodos, next)
 			}
-			fmt.Printf("Migrated %d finished todo(s) to the database and removed them from the todo file.\n", migratedCount)
-		} else {
-			fmt.Println("No finished todos older than 7 days found for migration.")
 		}
 	}
 
-	return nil
+	// Update the todo file once every task has been checked.
+	if len(archivedTodos) > 0 {
+		if err := SaveTodos(todoPath, remainingTodos); err != nil {
+			return nil, fmt.Errorf("error saving todos: %w", err)
+		}
+	}
+	return archivedTodos, nil
+}
+
+// hasOpenOccurrence reports whether todos contains an unfinished task of the given recurring series.
+func hasOpenOccurrence(todos []Todo, series string) bool {
+	for _, t := range todos {
+		if t.CompletedDate.IsZero() && seriesOf(t) == series {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,45 @@
+package todo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/johnjallday/flow-workspace/internal/shell"
+)
+
+// PromptNotes shows the current notes of a task and reads replacement notes,
+// one line at a time until an empty line. It returns false if the notes should
+// be left unchanged; entering a single "-" clears them.
+func PromptNotes(sh *shell.Shell, t Todo) ([]string, bool) {
+	if len(t.Notes) == 0 {
+		fmt.Println("This task has no notes.")
+	} else {
+		fmt.Println("Current notes:")
+		for _, note := range t.Notes {
+			fmt.Println("  " + note)
+		}
+	}
+
+	fmt.Println("Enter new notes, one line at a time, and finish with an empty line.")
+	fmt.Println("Leave the first line empty to keep the current notes, or enter '-' to clear them.")
+	var notes []string
+	for {
+		line, err := sh.Ask("> ")
+		if err != nil {
+			fmt.Println("Error reading notes:", err)
+			return nil, false
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if line == "-" && len(notes) == 0 {
+			return nil, true
+		}
+		notes = append(notes, line)
+	}
+	if len(notes) == 0 {
+		return nil, false
+	}
+	return notes, true
+}
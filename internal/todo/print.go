@@ -3,6 +3,8 @@ package todo
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/olekukonko/tablewriter"
@@ -44,25 +46,39 @@ func PrintTodos(todos []Todo) {
 	completeStatus := color.New(color.FgGreen)
 	ongoingStatus := color.New(color.FgYellow) // Added ongoing status color style.
 	incompleteStatus := color.New(color.FgRed)
+	blockedStatus := color.New(color.FgMagenta)
 	descIncomplete := color.New(color.FgWhite, color.Bold)
 	descComplete := color.New(color.FgHiBlack)
+	tagStyle := color.New(color.FgCyan)
+	overdueStyle := color.New(color.FgRed, color.Bold)
+	dueSoonStyle := color.New(color.FgYellow)
+	now := time.Now()
+	priorityStyles := map[int]*color.Color{
+		1: color.New(color.FgRed, color.Bold),
+		2: color.New(color.FgYellow),
+		3: color.New(color.FgBlue),
+		4: color.New(color.FgWhite),
+	}
 
 	// Set the table header based on the chosen mode.
 	switch mode {
 	case "compact":
-		table.SetHeader([]string{"No.", "Status", "Description"})
+		table.SetHeader([]string{"No.", "Status", "Pri", "Description"})
 	case "medium":
-		table.SetHeader([]string{"No.", "Status", "Description", "Due Date"})
+		table.SetHeader([]string{"No.", "ID", "Status", "Pri", "Description", "Tags", "Due Date"})
 	case "full":
-		table.SetHeader([]string{"No.", "Status", "Description", "Due Date", "Created", "Project", "Workspace"})
+		table.SetHeader([]string{"No.", "ID", "Status", "Pri", "Description", "Tags", "Due Date", "Created", "Project", "Workspace"})
 	}
 
 	// Append each TODO as a row in the table.
+	progresses := subtaskProgress(todos)
 	for i, t := range todos {
 		// Choose a colored status string.
 		var status string
 		if !t.CompletedDate.IsZero() {
 			status = completeStatus.Sprint("[x]")
+		} else if t.Blocked {
+			status = blockedStatus.Sprint("[!]")
 		} else if t.Ongoing { // New condition for ongoing tasks.
 			status = ongoingStatus.Sprint("[~]")
 		} else {
@@ -70,17 +86,43 @@ func PrintTodos(todos []Todo) {
 		}
 
 		// Colorize the description differently if the task is completed.
+		// Subtasks are indented and parents show how many subtasks are done.
+		description := strings.Repeat("  ", t.Depth) + t.Description
+		if p, ok := progresses[taskKey(t, t.ID)]; ok {
+			description += fmt.Sprintf(" (%d/%d)", p.done, p.total)
+		}
 		var desc string
 		if !t.CompletedDate.IsZero() {
-			desc = descComplete.Sprint(t.Description)
+			desc = descComplete.Sprint(description)
 		} else {
-			desc = descIncomplete.Sprint(t.Description)
+			desc = descIncomplete.Sprint(description)
+		}
+
+		// Colorize the priority; completed tasks are not highlighted.
+		priority := FormatPriority(t.Priority)
+		if style, ok := priorityStyles[t.Priority]; ok && t.CompletedDate.IsZero() {
+			priority = style.Sprint(priority)
+		}
+
+		// Show labels, contexts and generic tags together.
+		tags := FormatTags(t)
+		if t.Recur != "" {
+			tags = strings.TrimSpace(tags + " every:" + t.Recur)
+		}
+		if len(t.BlockedBy) > 0 {
+			tags = strings.TrimSpace(tags + " blocked-by:" + strings.Join(t.BlockedBy, ","))
+		}
+		if tags != "" {
+			tags = tagStyle.Sprint(tags)
 		}
 
 		// Format dates if set.
-		dueDate := ""
-		if !t.DueDate.IsZero() {
-			dueDate = t.DueDate.Format("2006-01-02")
+		// Highlight deadlines that have passed or are coming up.
+		dueDate := FormatDue(t)
+		if t.IsOverdue(now) {
+			dueDate = overdueStyle.Sprint(dueDate)
+		} else if t.IsDueSoon(now) {
+			dueDate = dueSoonStyle.Sprint(dueDate)
 		}
 		created := ""
 		if !t.CreatedDate.IsZero() {
@@ -91,11 +133,11 @@ func PrintTodos(todos []Todo) {
 		rowNo := fmt.Sprintf("%d", i+1)
 		switch mode {
 		case "compact":
-			table.Append([]string{rowNo, status, desc})
+			table.Append([]string{rowNo, status, priority, desc})
 		case "medium":
-			table.Append([]string{rowNo, status, desc, dueDate})
+			table.Append([]string{rowNo, t.ID, status, priority, desc, tags, dueDate})
 		case "full":
-			table.Append([]string{rowNo, status, desc, dueDate, created, t.ProjectName, t.WorkspaceName})
+			table.Append([]string{rowNo, t.ID, status, priority, desc, tags, dueDate, created, t.ProjectName, t.WorkspaceName})
 		}
 	}
 
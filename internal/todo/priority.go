@@ -0,0 +1,94 @@
+package todo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxPriority is the lowest priority a task can have; 1 is the highest and 0 means unset.
+const MaxPriority = 4
+
+// ParsePriority converts user input such as "1", "p2", "#p:3" or the
+// todo.txt style "B" / "(B)" into a priority between 1 and MaxPriority.
+// "0" or "none" return 0, which clears the priority.
+func ParsePriority(input string) (int, error) {
+	value := strings.ToLower(strings.TrimSpace(input))
+	value = strings.TrimPrefix(value, "#p:")
+	value = strings.Trim(value, "()")
+	if value == "none" {
+		return 0, nil
+	}
+	if len(value) == 1 && value[0] >= 'a' && value[0] < 'a'+MaxPriority {
+		return int(value[0]-'a') + 1, nil
+	}
+	p, err := strconv.Atoi(strings.TrimPrefix(value, "p"))
+	if err != nil || p < 0 || p > MaxPriority {
+		return 0, fmt.Errorf("invalid priority '%s' (use 1-%d or A-%c)", input, MaxPriority, 'A'+MaxPriority-1)
+	}
+	return p, nil
+}
+
+// FormatPriority returns the display form of a priority, e.g. "P1", or an empty string if unset.
+func FormatPriority(p int) string {
+	if p == 0 {
+		return ""
+	}
+	return fmt.Sprintf("P%d", p)
+}
+
+// SortByPriority orders todos by priority (unset last), then by due date
+// (tasks without a due date last), then by creation date. The sort is stable
+// so tasks that compare equal keep their file order. Subtasks are sorted among
+// their siblings and stay directly below their parent.
+func SortByPriority(todos []Todo) {
+	less := func(a, b Todo) bool {
+		if pa, pb := priorityRank(a.Priority), priorityRank(b.Priority); pa != pb {
+			return pa < pb
+		}
+		if a.DueDate.IsZero() != b.DueDate.IsZero() {
+			return !a.DueDate.IsZero()
+		}
+		if !a.DueAt().Equal(b.DueAt()) {
+			return a.DueAt().Before(b.DueAt())
+		}
+		return a.CreatedDate.Before(b.CreatedDate)
+	}
+
+	// Group the tasks by parent, keyed by taskKey as IDs are only unique within a file.
+	present := make(map[string]bool)
+	for _, t := range todos {
+		present[taskKey(t, t.ID)] = true
+	}
+	children := make(map[string][]Todo)
+	var roots []Todo
+	for _, t := range todos {
+		if t.ParentID != "" && present[taskKey(t, t.ParentID)] {
+			children[taskKey(t, t.ParentID)] = append(children[taskKey(t, t.ParentID)], t)
+		} else {
+			roots = append(roots, t)
+		}
+	}
+
+	// Lay the tree back out depth first.
+	sorted := make([]Todo, 0, len(todos))
+	var walk func(group []Todo)
+	walk = func(group []Todo) {
+		sort.SliceStable(group, func(i, j int) bool { return less(group[i], group[j]) })
+		for _, t := range group {
+			sorted = append(sorted, t)
+			walk(children[taskKey(t, t.ID)])
+		}
+	}
+	walk(roots)
+	copy(todos, sorted)
+}
+
+// priorityRank maps an unset priority after every explicit one.
+func priorityRank(p int) int {
+	if p == 0 {
+		return MaxPriority + 1
+	}
+	return p
+}
@@ -0,0 +1,152 @@
+package todo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// weekdays maps the accepted weekday spellings of a recurrence rule.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ValidateRecurrence checks a recurrence rule as written in the "#every" tag:
+// "daily", "weekly", a weekday such as "fri", "Nd" for every N days, "Nw" for
+// every N weeks, or "monthly".
+func ValidateRecurrence(rule string) error {
+	_, err := recurrenceStep(rule)
+	return err
+}
+
+// NextDue returns the due date of the occurrence that follows a recurring task
+// completed at done. The schedule continues from the task's due date if it has
+// one, otherwise from the completion date, and the result is always after done.
+func NextDue(rule string, due, done time.Time) (time.Time, error) {
+	advance, err := recurrenceStep(rule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	doneDay := time.Date(done.Year(), done.Month(), done.Day(), 0, 0, 0, 0, time.UTC)
+	start := doneDay
+	if !due.IsZero() {
+		start = time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	// Count the periods from the start rather than stepping from the last
+	// result, so a monthly task due on the 31st keeps its day where it can.
+	for n := 1; ; n++ {
+		if next := advance(start, n); next.After(doneDay) {
+			return next, nil
+		}
+	}
+}
+
+// recurrenceStep returns a function that advances a date by n periods of rule.
+func recurrenceStep(rule string) (func(d time.Time, n int) time.Time, error) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	switch rule {
+	case "daily", "day":
+		return func(d time.Time, n int) time.Time { return d.AddDate(0, 0, n) }, nil
+	case "weekly", "week":
+		return func(d time.Time, n int) time.Time { return d.AddDate(0, 0, 7*n) }, nil
+	case "monthly", "month":
+		return addMonths, nil
+	}
+	if wd, ok := weekdays[rule]; ok {
+		return func(d time.Time, n int) time.Time {
+			days := (int(wd) - int(d.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			return d.AddDate(0, 0, days+7*(n-1))
+		}, nil
+	}
+	if len(rule) > 1 {
+		every, err := strconv.Atoi(rule[:len(rule)-1])
+		if err == nil && every > 0 {
+			switch rule[len(rule)-1] {
+			case 'd':
+				return func(d time.Time, n int) time.Time { return d.AddDate(0, 0, every*n) }, nil
+			case 'w':
+				return func(d time.Time, n int) time.Time { return d.AddDate(0, 0, 7*every*n) }, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid recurrence '%s' (use daily, weekly, mon-sun, Nd, Nw or monthly)", rule)
+}
+
+// addMonths returns d moved n months ahead. Unlike AddDate, a day that the
+// target month doesn't have becomes its last day, so Jan 31 is followed by
+// Feb 28 (or 29) rather than Mar 3.
+func addMonths(d time.Time, n int) time.Time {
+	first := time.Date(d.Year(), d.Month()+time.Month(n), 1, d.Hour(), d.Minute(), d.Second(), d.Nanosecond(), d.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(d.Day(), lastDay)-1)
+}
+
+// nextOccurrence builds the open task that follows the completed recurring task t.
+func nextOccurrence(t Todo, ids map[string]bool) (Todo, error) {
+	due, err := NextDue(t.Recur, t.DueDate, t.CompletedDate)
+	if err != nil {
+		return Todo{}, err
+	}
+	next := t
+	next.ID = newTodoID(ids)
+	next.Series = seriesOf(t)
+	next.CompletedDate = time.Time{}
+	next.Ongoing = false
+	now := time.Now()
+	next.CreatedDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	next.DueDate = due
+	// Keep the time of day and zone of a timed deadline.
+	if t.DueHasTime {
+		d := t.DueDate
+		next.DueDate = time.Date(due.Year(), due.Month(), due.Day(), d.Hour(), d.Minute(), 0, 0, d.Location())
+	}
+	// Copy the slices so the two tasks don't share backing arrays.
+	next.Tags = append([]Tag(nil), t.Tags...)
+	next.Labels = append([]string(nil), t.Labels...)
+	next.Contexts = append([]string(nil), t.Contexts...)
+	next.Notes = append([]string(nil), t.Notes...)
+	next.BlockedBy = append([]string(nil), t.BlockedBy...)
+	return next, nil
+}
+
+// seriesOf returns the ID that links all occurrences of a recurring task,
+// which is the ID of its first occurrence.
+func seriesOf(t Todo) string {
+	if t.Series != "" {
+		return t.Series
+	}
+	return t.ID
+}
+
+// completeTodo marks t as completed in doc. For a recurring task the next
+// occurrence is added below it and its subtasks, and its ID is returned.
+func completeTodo(doc *Document, t Todo) (string, error) {
+	t.CompletedDate = time.Now()
+	t.Ongoing = false
+	if t.Recur == "" {
+		return "", doc.Update(t)
+	}
+
+	t.Series = seriesOf(t)
+	next, err := nextOccurrence(t, doc.ids())
+	if err != nil {
+		return "", err
+	}
+	if err := doc.Update(t); err != nil {
+		return "", err
+	}
+	if err := doc.InsertAfter(t.ID, next); err != nil {
+		return "", err
+	}
+	return next.ID, nil
+}
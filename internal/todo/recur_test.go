@@ -0,0 +1,93 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestNextDue(t *testing.T) {
+	tests := []struct {
+		rule, due, done, want string
+	}{
+		{"daily", "2026-03-10", "2026-03-10", "2026-03-11"},
+		{"daily", "2026-03-01", "2026-03-10", "2026-03-11"},
+		{"weekly", "2026-03-10", "2026-03-09", "2026-03-17"},
+		{"2w", "2026-03-10", "2026-03-10", "2026-03-24"},
+		{"3d", "2026-03-10", "2026-03-15", "2026-03-16"},
+		{"fri", "2026-03-13", "2026-03-13", "2026-03-20"},
+		{"fri", "", "2026-03-11", "2026-03-13"},
+		{"Mon", "2026-03-02", "2026-03-20", "2026-03-23"},
+		{"monthly", "2026-03-15", "2026-03-15", "2026-04-15"},
+		{"monthly", "2026-01-31", "2026-01-31", "2026-02-28"},
+		{"monthly", "2028-01-31", "2028-01-31", "2028-02-29"},
+		{"monthly", "2026-01-31", "2026-03-05", "2026-03-31"},
+		{"monthly", "2026-03-31", "2026-03-31", "2026-04-30"},
+		{"monthly", "2026-12-31", "2026-12-31", "2027-01-31"},
+		{"monthly", "", "2026-05-31", "2026-06-30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule+" "+tt.due+" "+tt.done, func(t *testing.T) {
+			var due time.Time
+			if tt.due != "" {
+				due = date(tt.due)
+			}
+			got, err := NextDue(tt.rule, due, date(tt.done))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := date(tt.want); !got.Equal(want) {
+				t.Errorf("NextDue = %s, want %s", got.Format("2006-01-02"), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRecurrence(t *testing.T) {
+	for _, rule := range []string{"daily", "weekly", "monthly", "fri", "Sunday", "3d", "2w"} {
+		if err := ValidateRecurrence(rule); err != nil {
+			t.Errorf("ValidateRecurrence(%q) = %v", rule, err)
+		}
+	}
+	for _, rule := range []string{"", "sometimes", "0d", "-1w", "3m", "d"} {
+		if err := ValidateRecurrence(rule); err == nil {
+			t.Errorf("ValidateRecurrence(%q) succeeded", rule)
+		}
+	}
+}
+
+func TestCompleteRecurringTodoKeepsSubtasks(t *testing.T) {
+	doc := ParseDocument("- [ ] report #due:2026-01-31 #every:monthly #id:aaaa\n  - [ ] draft #id:bbbb\n    note\n  - [ ] send #id:cccc\n- [ ] other #id:dddd\n")
+	parent, _ := doc.Get("aaaa")
+	nextID, err := completeTodo(doc, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	todos := doc.Todos()
+	var order []string
+	for _, td := range todos {
+		order = append(order, td.ID)
+	}
+	want := []string{"aaaa", "bbbb", "cccc", nextID, "dddd"}
+	if strings.Join(order, " ") != strings.Join(want, " ") {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for _, id := range []string{"bbbb", "cccc"} {
+		if sub, _ := doc.Get(id); sub.ParentID != "aaaa" {
+			t.Errorf("subtask %s has parent %q, want aaaa", id, sub.ParentID)
+		}
+	}
+	next, _ := doc.Get(nextID)
+	if next.ParentID != "" || next.Series != "aaaa" || next.Recur != "monthly" {
+		t.Errorf("next occurrence = %+v", next)
+	}
+}
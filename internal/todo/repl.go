@@ -1,20 +1,19 @@
 package todo
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 
+	"github.com/johnjallday/flow-workspace/internal/config"
 	db "github.com/johnjallday/flow-workspace/internal/db/todo"
+	"github.com/johnjallday/flow-workspace/internal/shell"
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
 )
 
 // StartTodoREPL is the interactive REPL for a single todo.md file using TodoService.
-func StartTodoREPL(dbPath string, todoFilePath string) {
-	reader := bufio.NewReader(os.Stdin)
+func StartTodoREPL(cfg *config.Config, todoFilePath string) {
+	dbPath := cfg.DBPath
 
 	// Initialize the database.
 	mydb, err := db.InitDB(dbPath)
@@ -22,181 +21,302 @@ func StartTodoREPL(dbPath string, todoFilePath string) {
 		fmt.Println("Error connecting to db:", err)
 		return
 	}
+	defer mydb.Close()
 
-	// Migrate finished todos from the file to the database.
-	MigrateFinishedTodos(todoFilePath, mydb)
+	// Create an instance of TodoService for the project's store.
+	service, err := NewTodoService(mydb, todoFilePath)
+	if err != nil {
+		fmt.Println("Error opening todos:", err)
+		return
+	}
+
+	// Archive finished todos; the report is shown once the screen is cleared.
+	policy := ProjectArchivePolicy(cfg.ArchivePolicy(), filepath.Dir(todoFilePath))
+	archived, err := ArchiveFinishedTodos(service, todoFilePath, mydb, policy, false)
+	if err != nil {
+		fmt.Println("Error archiving finished todos:", err)
+	}
+	if policy.Quiet {
+		archived = nil
+	}
 
-	// Create an instance of TodoService.
-	service := NewFileTodoService(todoFilePath)
+	sh := shell.New("TODO REPL", "todo", fmt.Sprintf("\n[todo:%s] >> ", filepath.Base(todoFilePath)))
+	sh.Pause = true
 
-	// REPL loop.
-	for {
-		clearScreen()
+	// The tasks of the file and the ones listed on screen, which task numbers
+	// refer to: those matching filter, or all of them if it is empty.
+	var todos, listed []Todo
+	var filter string
+	autoSync := ProjectSync(filepath.Dir(todoFilePath))
+	sh.Before = func() {
+		shell.ClearScreen()
 		fmt.Println("dbPath:", dbPath)
-		printHelp()
+		PrintArchiveReport(archived, false)
+		archived = nil
 
-		// List current todos.
-		todos, err := service.ListTodos()
-		if err != nil {
-			fmt.Printf("Error loading todos: %v\n", err)
-		} else {
-			PrintTodos(todos)
+		// Pick up changes made in an editor or in a database-backed view.
+		if autoSync {
+			if result, err := SyncProject(mydb, todoFilePath, PromptSyncConflict(sh)); err != nil {
+				fmt.Println("Error syncing todos:", err)
+			} else if len(result.Conflicts) > 0 {
+				PrintSyncResult(result)
+			}
 		}
+		sh.PrintHelp()
 
-		fmt.Printf("\n[todo:%s] >> ", filepath.Base(todoFilePath))
-		line, err := reader.ReadString('\n')
+		// List current todos.
+		var err error
+		todos, err = service.ListTodos()
+		listed = todos
 		if err != nil {
-			fmt.Println("Error reading input:", err)
+			fmt.Printf("Error loading todos: %v\n", err)
 			return
 		}
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+		if filter != "" {
+			listed = FilterTodos(todos, filter)
+			fmt.Printf("Tasks matching \"%s\" (use filter without a filter to show all):\n", filter)
 		}
-		parts := strings.SplitN(line, " ", 2)
-		command := strings.ToLower(parts[0])
-
-		switch command {
-		case "exit":
-			fmt.Println("Exiting TODO REPL. Goodbye!")
-			return
-		case "add":
-			// Prompt for description and due date.
-			fmt.Print("Enter task description: ")
-			description, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Println("Error reading description:", err)
-				continue
-			}
-			description = strings.TrimSpace(description)
-			if description == "" {
-				fmt.Println("Task description cannot be empty.")
-				continue
-			}
-
-			fmt.Print("Enter due date (YYYY-MM-DD) or leave empty: ")
-			dueDate, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Println("Error reading due date:", err)
-				continue
-			}
-			dueDate = strings.TrimSpace(dueDate)
+		PrintTodos(listed)
+	}
 
-			if err := service.AddTodo(description, dueDate); err != nil {
-				fmt.Println("Error adding task:", err)
-			} else {
-				fmt.Println("Task added successfully.")
-			}
-		case "complete":
-			if len(todos) == 0 {
-				fmt.Println("No tasks available to complete.")
-				break
-			}
-			fmt.Print("Enter the task number to complete: ")
-			input, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Println("Error reading input:", err)
-				continue
-			}
-			input = strings.TrimSpace(input)
-			index, err := strconv.Atoi(input)
-			if err != nil || index < 1 || index > len(todos) {
-				fmt.Println("Invalid task number.")
-				continue
-			}
-			if err := service.CompleteTodo(index - 1); err != nil {
-				fmt.Println("Error completing task:", err)
-			} else {
+	sh.Register(
+		&shell.Command{
+			Name: "add",
+			Args: "[description]",
+			Help: "Add a new task (use #every:weekly, #every:fri, #every:3d, ... to repeat it)",
+			Run: func(c *shell.Context) error {
+				description, err := c.Arg("Enter task description: ")
+				if err != nil {
+					return err
+				}
+				if description == "" {
+					fmt.Println("Task description cannot be empty.")
+					return nil
+				}
+				dueDate, err := c.Shell.Ask(fmt.Sprintf("Enter due date (%s) or leave empty: ", DateInputHelp))
+				if err != nil {
+					return err
+				}
+				priority, err := c.Shell.Ask("Enter priority (1-4 or A-D) or leave empty: ")
+				if err != nil {
+					return err
+				}
+				id, err := service.AddTodo(description, dueDate, priority)
+				if err != nil {
+					return fmt.Errorf("adding task: %w", err)
+				}
+				fmt.Printf("Task %s added successfully.\n", id)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name:     "complete",
+			Aliases:  []string{"done"},
+			Args:     "[task]",
+			Complete: func() []string { return TaskNumbers(listed) },
+			Help:     "Mark a task as completed",
+			Run: func(c *shell.Context) error {
+				id, err := TaskArg(c, listed, todos, "Enter the task number or ID to complete: ")
+				if err != nil {
+					return err
+				}
+				// Offer to complete open subtasks along with their parent.
+				subtaskIDs, ok := PromptCompleteSubtasks(c.Shell, todos, id)
+				if !ok {
+					return nil
+				}
+				for _, subID := range subtaskIDs {
+					if err := service.CompleteTodoByID(subID); err != nil {
+						fmt.Println("Error completing subtask:", err)
+					}
+				}
+				if err := service.CompleteTodoByID(id); err != nil {
+					return fmt.Errorf("completing task: %w", err)
+				}
 				fmt.Println("Task marked as completed.")
-			}
-		case "delete":
-			if len(todos) == 0 {
-				fmt.Println("No tasks available to delete.")
-				break
-			}
-			fmt.Print("Enter the task number to delete: ")
-			input, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Println("Error reading input:", err)
-				continue
-			}
-			input = strings.TrimSpace(input)
-			index, err := strconv.Atoi(input)
-			if err != nil || index < 1 || index > len(todos) {
-				fmt.Println("Invalid task number.")
-				continue
-			}
-			if err := service.DeleteTodo(index - 1); err != nil {
-				fmt.Println("Error deleting task:", err)
-			} else {
+				return nil
+			},
+		},
+		&shell.Command{
+			Name:     "delete",
+			Aliases:  []string{"rm"},
+			Args:     "[task]",
+			Complete: func() []string { return TaskNumbers(listed) },
+			Help:     "Delete a task",
+			Exact:    true,
+			Run: func(c *shell.Context) error {
+				id, err := TaskArg(c, listed, todos, "Enter the task number or ID to delete: ")
+				if err != nil {
+					return err
+				}
+				if err := service.DeleteTodoByID(id); err != nil {
+					return fmt.Errorf("deleting task: %w", err)
+				}
 				fmt.Println("Task deleted successfully.")
-			}
-		case "edit":
-			if len(todos) == 0 {
-				fmt.Println("No tasks available to edit.")
-				break
-			}
-			fmt.Print("Enter the task number to edit: ")
-			input, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Println("Error reading input:", err)
-				continue
-			}
-			input = strings.TrimSpace(input)
-			index, err := strconv.Atoi(input)
-			if err != nil || index < 1 || index > len(todos) {
-				fmt.Println("Invalid task number.")
-				continue
-			}
-			fmt.Print("Enter new description (leave empty to keep current): ")
-			newDescription, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Println("Error reading description:", err)
-				continue
-			}
-			newDescription = strings.TrimSpace(newDescription)
-			fmt.Print("Enter new due date (YYYY-MM-DD, leave empty to keep current): ")
-			newDueDate, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Println("Error reading due date:", err)
-				continue
-			}
-			newDueDate = strings.TrimSpace(newDueDate)
-			fmt.Print("Enter new status (ongoing/complete, leave empty to keep current): ")
-			newStatus, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Println("Error reading status:", err)
-				continue
-			}
-			newStatus = strings.TrimSpace(newStatus)
-			if err := service.EditTodo(index-1, newDescription, newDueDate, newStatus); err != nil {
-				fmt.Println("Error editing task:", err)
-			} else {
-				fmt.Println("Task edited successfully.")
-			}
-		case "weekly":
-			fmt.Println("Running weekly review...")
-			ReviewWeekly(todos)
-			// (Add any weekly review functionality here.)
-		default:
-			fmt.Println("Unknown command. Type 'help' for available commands.")
-		}
+				return nil
+			},
+		},
+		&shell.Command{
+			Name:     "edit",
+			Args:     "[task]",
+			Complete: func() []string { return TaskNumbers(listed) },
+			Help:     "Edit a task (update description, due date, status and/or priority)",
+			Run: func(c *shell.Context) error {
+				id, err := TaskArg(c, listed, todos, "Enter the task number or ID to edit: ")
+				if err != nil {
+					return err
+				}
+				return EditTask(c.Shell, service, todos, id)
+			},
+		},
+		&shell.Command{
+			Name:     "note",
+			Args:     "[task]",
+			Complete: func() []string { return TaskNumbers(listed) },
+			Help:     "View or replace the notes written below a task",
+			Run: func(c *shell.Context) error {
+				id, err := TaskArg(c, listed, todos, "Enter the task number or ID to edit notes for: ")
+				if err != nil {
+					return err
+				}
+				return EditNotes(c.Shell, service, todos[FindTodoByID(todos, id)])
+			},
+		},
+		&shell.Command{
+			Name: "filter",
+			Args: "[filter]",
+			Help: "List only tasks matching #label, @context, key:value or text; no filter lists all",
+			Run: func(c *shell.Context) error {
+				query, err := c.Arg("Enter filter (#label @context key:value text), or leave empty to list all tasks: ")
+				if err != nil {
+					return err
+				}
+				filter = strings.TrimSpace(query)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "archive",
+			Help: "Browse archived tasks of this project and restore one",
+			Run: func(c *shell.Context) error {
+				projectDir := filepath.Dir(todoFilePath)
+				BrowseArchive(mydb, c.Shell, ProjectArchiveScope(projectDir), func(ArchivedTodo) (string, error) {
+					return projectDir, nil
+				})
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "archive-finished",
+			Help: "Archive the finished tasks selected by the archive policy now",
+			Run: func(c *shell.Context) error {
+				archived, err := ArchiveFinishedTodos(service, todoFilePath, mydb, policy, true)
+				if err != nil {
+					fmt.Println("Error archiving finished todos:", err)
+				}
+				PrintArchiveReport(archived, true)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "sync",
+			Help: "Sync todo.md with the tasks stored in the database",
+			Run: func(c *shell.Context) error {
+				result, err := SyncProject(mydb, todoFilePath, PromptSyncConflict(c.Shell))
+				if err != nil {
+					return fmt.Errorf("syncing todos: %w", err)
+				}
+				PrintSyncResult(result)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "weekly",
+			Help: "Run the weekly review for this TODO file",
+			Run: func(c *shell.Context) error {
+				fmt.Println("Running weekly review...")
+				ReviewWeekly(todos, cfg.WeekStartDay())
+				return nil
+			},
+		},
+	)
+	sh.Run()
+}
 
-		fmt.Print("Press Enter to continue...")
-		_, _ = reader.ReadString('\n')
+// TaskArg returns the ID of the task given as the argument of a command, by
+// number or ID as for ResolveListedTodoID, asking for it with prompt if there is none.
+func TaskArg(c *shell.Context, listed []Todo, todos []Todo, prompt string) (string, error) {
+	if len(todos) == 0 {
+		return "", fmt.Errorf("no tasks available")
 	}
+	input, err := c.Arg(prompt)
+	if err != nil {
+		return "", err
+	}
+	id, err := ResolveListedTodoID(listed, todos, input)
+	if err != nil {
+		return "", fmt.Errorf("invalid task: %w", err)
+	}
+	return id, nil
 }
 
-func printHelp() {
-	fmt.Println(`Available commands (TODO REPL):
-  add       - Add a new task
-  complete  - Mark a task as completed
-  delete    - Delete a task
-  edit      - Edit a task (update description, due date, and/or status)
-  weekly    - Run the weekly review for this TODO file
-  exit      - Exit the TODO REPL`)
+// EditTask asks for the new description, due date, status and priority of the
+// task of todos with the given ID, keeping those left empty, and saves them.
+// Completing the task offers to complete its open subtasks as "complete" does.
+func EditTask(sh *shell.Shell, service TodoService, todos []Todo, id string) error {
+	t := todos[FindTodoByID(todos, id)]
+	fmt.Printf("Current description: %s\n", t.Description)
+	newDescription, err := sh.Ask("Enter new description (leave empty to keep current): ")
+	if err != nil {
+		return err
+	}
+	if due := FormatDue(t); due != "" {
+		fmt.Printf("Current due date: %s\n", due)
+	}
+	newDueDate, err := sh.Ask(fmt.Sprintf("Enter new due date (%s), leave empty to keep current: ", DateInputHelp))
+	if err != nil {
+		return err
+	}
+	newStatus, err := sh.Ask("Enter new status (ongoing/complete, leave empty to keep current): ")
+	if err != nil {
+		return err
+	}
+	var subtaskIDs []string
+	if strings.EqualFold(newStatus, "complete") && t.CompletedDate.IsZero() {
+		var ok bool
+		if subtaskIDs, ok = PromptCompleteSubtasks(sh, todos, t.ID); !ok {
+			return nil
+		}
+	}
+	if priority := FormatPriority(t.Priority); priority != "" {
+		fmt.Printf("Current priority: %s\n", priority)
+	}
+	newPriority, err := sh.Ask("Enter new priority (1-4, A-D or none, leave empty to keep current): ")
+	if err != nil {
+		return err
+	}
+	for _, subID := range subtaskIDs {
+		if err := service.CompleteTodoByID(subID); err != nil {
+			fmt.Println("Error completing subtask:", err)
+		}
+	}
+	if err := service.EditTodoByID(t.ID, newDescription, newDueDate, newStatus, newPriority); err != nil {
+		return fmt.Errorf("editing task: %w", err)
+	}
+	fmt.Println("Task edited successfully.")
+	return nil
 }
 
-func clearScreen() {
-	fmt.Print("\033[H\033[2J")
+// EditNotes shows the notes of a task and replaces them with the ones entered.
+func EditNotes(sh *shell.Shell, service TodoService, t Todo) error {
+	notes, ok := PromptNotes(sh, t)
+	if !ok {
+		fmt.Println("Notes unchanged.")
+		return nil
+	}
+	if err := service.SetNotesByID(t.ID, notes); err != nil {
+		return fmt.Errorf("saving notes: %w", err)
+	}
+	fmt.Println("Notes saved.")
+	return nil
 }
@@ -8,11 +8,18 @@ import (
 )
 
 // TodoService defines the operations for managing TODO items.
+// Index based methods address a task by its position in ListTodos; the ByID
+// variants use the task's stable "#id" tag and should be preferred whenever the
+// file may change between listing and acting.
 type TodoService interface {
-	AddTodo(description, dueDate string) error
-	EditTodo(index int, newDescription, newDueDate, newStatus string) error
+	AddTodo(description, dueDate, priority string) (string, error)
+	EditTodo(index int, newDescription, newDueDate, newStatus, newPriority string) error
 	DeleteTodo(index int) error
 	CompleteTodo(index int) error
+	EditTodoByID(id, newDescription, newDueDate, newStatus, newPriority string) error
+	DeleteTodoByID(id string) error
+	CompleteTodoByID(id string) error
+	SetNotesByID(id string, notes []string) error
 	ListTodos() ([]Todo, error)
 }
 
@@ -26,8 +33,20 @@ func NewFileTodoService(todoFilePath string) *FileTodoService {
 	return &FileTodoService{todoFilePath: todoFilePath}
 }
 
-// AddTodo creates a new task and appends it to the todo file.
-func (s *FileTodoService) AddTodo(description, dueDate string) error {
+// AddTodo creates a new task, appends it to the todo file and returns its ID.
+// dueDate may be empty or anything accepted by NormalizeDue, priority may be
+// empty or anything accepted by ParsePriority.
+func (s *FileTodoService) AddTodo(description, dueDate, priority string) (string, error) {
+	p, err := ParsePriority(priority)
+	if priority != "" && err != nil {
+		return "", err
+	}
+	// Accept relative dates such as "tomorrow" or "+3d", with an optional time and zone.
+	dueDate, err = NormalizeDue(dueDate)
+	if err != nil {
+		return "", err
+	}
+
 	// Determine the project path from the todo file path.
 	projectPath := filepath.Dir(s.todoFilePath)
 
@@ -35,118 +54,214 @@ func (s *FileTodoService) AddTodo(description, dueDate string) error {
 	projectName := tagProject(projectPath)
 	workspaceName := tagWorkspace(projectPath)
 
-	// Build the task line.
-	taskLine := buildTaskLine(description, dueDate, projectName, workspaceName)
-
-	// Read the existing file content.
-	content, err := ReadFileContent(s.todoFilePath)
+	doc, err := LoadDocument(s.todoFilePath)
 	if err != nil {
-		return fmt.Errorf("failed to read '%s': %w", s.todoFilePath, err)
+		return "", fmt.Errorf("failed to read '%s': %w", s.todoFilePath, err)
 	}
 
-	// Insert new task after the "# todo" header.
-	lines := strings.Split(content, "\n")
-	insertIndex := 0
-	for i, line := range lines {
-		if strings.HasPrefix(strings.TrimSpace(line), "# todo") {
-			insertIndex = i + 1
-			break
-		}
-	}
-	for insertIndex < len(lines) && strings.TrimSpace(lines[insertIndex]) == "" {
-		insertIndex++
+	// Build the task line with an ID that does not collide with existing
+	// tasks and insert it after the "# todo" header.
+	taskLine := buildTaskLine(newTodoID(doc.ids()), description, dueDate, p, projectName, workspaceName)
+	t, err := doc.AddLine(taskLine)
+	if err != nil {
+		return "", fmt.Errorf("invalid task: %w", err)
 	}
-	newLines := append(lines[:insertIndex], append([]string{taskLine}, lines[insertIndex:]...)...)
-	updatedContent := strings.Join(newLines, "\n")
 
-	return WriteFileContent(s.todoFilePath, updatedContent)
+	if err := doc.Save(s.todoFilePath); err != nil {
+		return "", err
+	}
+	return t.ID, nil
 }
 
-// ListTodos returns the list of todos from the file.
+// ListTodos returns the list of todos from the file. Tasks waiting for an
+// open task in this file or in another project of the workspace are marked as blocked.
 func (s *FileTodoService) ListTodos() ([]Todo, error) {
-	return LoadAllTodos(s.todoFilePath)
+	todos, err := LoadAllTodos(s.todoFilePath)
+	if err != nil {
+		return todos, err
+	}
+	// Blockers are looked up by project, but the tasks of the file itself are
+	// returned without the name of their project.
+	named := withProjectName(todos, filepath.Base(filepath.Dir(s.todoFilePath)))
+	MarkBlocked(named, append(siblingTodos(s.todoFilePath), named...))
+	for i := range todos {
+		todos[i].Blocked = named[i].Blocked
+	}
+	return todos, nil
 }
 
-// EditTodo updates the description, due date, and/or status of the task at the given index.
-func (s *FileTodoService) EditTodo(index int, newDescription, newDueDate, newStatus string) error {
+// idAt returns the ID of the task at the given index of the current file.
+func (s *FileTodoService) idAt(index int) (string, error) {
 	todos, err := LoadAllTodos(s.todoFilePath)
 	if err != nil {
-		return fmt.Errorf("error loading tasks: %w", err)
+		return "", fmt.Errorf("error loading tasks: %w", err)
 	}
-
 	if index < 0 || index >= len(todos) {
-		return fmt.Errorf("invalid task index")
+		return "", fmt.Errorf("invalid task index")
 	}
+	return todos[index].ID, nil
+}
 
-	selectedTask := todos[index]
-	// Update description if provided.
-	if newDescription != "" {
-		selectedTask.Description = newDescription
+// loadByID loads the todo file and returns it along with the task with the given ID.
+func (s *FileTodoService) loadByID(id string) (*Document, Todo, error) {
+	doc, err := LoadDocument(s.todoFilePath)
+	if err != nil {
+		return nil, Todo{}, fmt.Errorf("error loading tasks: %w", err)
+	}
+	t, ok := doc.Get(id)
+	if !ok {
+		return nil, Todo{}, fmt.Errorf("task '%s' not found", id)
 	}
+	return doc, t, nil
+}
 
-	// Update due date if provided.
-	if newDueDate != "" {
-		parsedDate, err := time.Parse("2006-01-02", newDueDate)
-		if err != nil {
-			return fmt.Errorf("invalid date format: %w", err)
-		}
-		selectedTask.DueDate = parsedDate
+// EditTodo updates the description, due date, status and/or priority of the task at the given index.
+func (s *FileTodoService) EditTodo(index int, newDescription, newDueDate, newStatus, newPriority string) error {
+	id, err := s.idAt(index)
+	if err != nil {
+		return err
 	}
+	return s.EditTodoByID(id, newDescription, newDueDate, newStatus, newPriority)
+}
 
-	// Update status if provided.
-	if newStatus != "" {
-		switch strings.ToLower(newStatus) {
-		case "ongoing":
-			selectedTask.Ongoing = true
-			// Clear any completion date if marking as ongoing.
-			selectedTask.CompletedDate = time.Time{}
-		case "complete":
-			selectedTask.CompletedDate = time.Now()
-			selectedTask.Ongoing = false
-		default:
-			return fmt.Errorf("invalid status option: %s", newStatus)
-		}
+// EditTodoByID updates the description, due date, status and/or priority of the task with the given ID.
+// Empty values leave the corresponding field unchanged; a priority of "0" or "none" clears it.
+func (s *FileTodoService) EditTodoByID(id, newDescription, newDueDate, newStatus, newPriority string) error {
+	doc, selectedTask, err := s.loadByID(id)
+	if err != nil {
+		return err
+	}
+
+	completing, err := applyEdit(&selectedTask, newDescription, newDueDate, newStatus, newPriority)
+	if err != nil {
+		return err
 	}
 
-	// Update the task in the slice.
-	todos[index] = selectedTask
+	// Completing a recurring task also schedules its next occurrence.
+	if completing {
+		if _, err := completeTodo(doc, selectedTask); err != nil {
+			return err
+		}
+		return doc.Save(s.todoFilePath)
+	}
 
-	// Save the updated todos back to the todo.md file.
-	return SaveTodos(s.todoFilePath, todos)
+	// Update the task and save the todo.md file; other lines are left untouched.
+	if err := doc.Update(selectedTask); err != nil {
+		return err
+	}
+	return doc.Save(s.todoFilePath)
 }
 
 // DeleteTodo removes the task at the given index.
 func (s *FileTodoService) DeleteTodo(index int) error {
-	todos, err := LoadAllTodos(s.todoFilePath)
+	id, err := s.idAt(index)
 	if err != nil {
-		return fmt.Errorf("error loading tasks: %w", err)
+		return err
 	}
+	return s.DeleteTodoByID(id)
+}
 
-	if index < 0 || index >= len(todos) {
-		return fmt.Errorf("invalid task index")
+// DeleteTodoByID removes the task with the given ID.
+func (s *FileTodoService) DeleteTodoByID(id string) error {
+	doc, _, err := s.loadByID(id)
+	if err != nil {
+		return err
 	}
 
-	todos = append(todos[:index], todos[index+1:]...)
-	return SaveTodos(s.todoFilePath, todos)
+	if err := doc.Delete(id); err != nil {
+		return err
+	}
+	return doc.Save(s.todoFilePath)
 }
 
 // CompleteTodo marks the task at the given index as completed.
 func (s *FileTodoService) CompleteTodo(index int) error {
-	todos, err := LoadAllTodos(s.todoFilePath)
+	id, err := s.idAt(index)
 	if err != nil {
-		return fmt.Errorf("error loading tasks: %w", err)
+		return err
 	}
+	return s.CompleteTodoByID(id)
+}
 
-	if index < 0 || index >= len(todos) {
-		return fmt.Errorf("invalid task index")
+// CompleteTodoByID marks the task with the given ID as completed. For a
+// recurring task the next occurrence is added below it.
+func (s *FileTodoService) CompleteTodoByID(id string) error {
+	doc, t, err := s.loadByID(id)
+	if err != nil {
+		return err
 	}
 
 	// Ensure the task is not already completed.
-	if !todos[index].CompletedDate.IsZero() {
+	if !t.CompletedDate.IsZero() {
 		return fmt.Errorf("task already completed")
 	}
 
-	todos[index].CompletedDate = time.Now()
-	todos[index].Ongoing = false
-	return SaveTodos(s.todoFilePath, todos)
+	if _, err := completeTodo(doc, t); err != nil {
+		return err
+	}
+	return doc.Save(s.todoFilePath)
+}
+
+// SetNotesByID replaces the notes written below the task with the given ID.
+func (s *FileTodoService) SetNotesByID(id string, notes []string) error {
+	doc, t, err := s.loadByID(id)
+	if err != nil {
+		return err
+	}
+
+	t.Notes = notes
+	if err := doc.Update(t); err != nil {
+		return err
+	}
+	return doc.Save(s.todoFilePath)
+}
+
+// applyEdit changes the fields of t for which a non-empty value is given and
+// reports whether the edit completes a task that was still open.
+// A priority of "0" or "none" clears it.
+func applyEdit(t *Todo, newDescription, newDueDate, newStatus, newPriority string) (bool, error) {
+	// Update description if provided.
+	if newDescription != "" {
+		t.Description = newDescription
+	}
+
+	// Update due date if provided.
+	if newDueDate != "" {
+		value, err := NormalizeDue(newDueDate)
+		if err != nil {
+			return false, err
+		}
+		parsedDate, hasTime, zone, err := parseDueTag(value)
+		if err != nil {
+			return false, err
+		}
+		t.DueDate, t.DueHasTime, t.DueZone = parsedDate, hasTime, zone
+	}
+
+	// Update status if provided.
+	completing := false
+	if newStatus != "" {
+		switch strings.ToLower(newStatus) {
+		case "ongoing":
+			t.Ongoing = true
+			// Clear any completion date if marking as ongoing.
+			t.CompletedDate = time.Time{}
+		case "complete":
+			completing = t.CompletedDate.IsZero()
+			t.CompletedDate = time.Now()
+			t.Ongoing = false
+		default:
+			return false, fmt.Errorf("invalid status option: %s", newStatus)
+		}
+	}
+
+	// Update priority if provided.
+	if newPriority != "" {
+		p, err := ParsePriority(newPriority)
+		if err != nil {
+			return false, err
+		}
+		t.Priority = p
+	}
+	return completing, nil
 }
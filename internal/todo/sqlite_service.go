@@ -0,0 +1,484 @@
+package todo
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/config"
+	"github.com/johnjallday/flow-workspace/internal/db/migrate"
+)
+
+// Status values of the rows in the todos table. Rows written before live tasks
+// were stored in the database default to statusArchived.
+const (
+	statusOpen     = "open"
+	statusDone     = "done"
+	statusArchived = "archived"
+)
+
+// todoColumns lists the columns read by scanTodo, in order.
+const todoColumns = `task_id, description, status, ongoing, priority, completed_date, created_date,
+	due_date, due, recur, series, parent_id, blocked_by, tags, notes, project_name, workspace_name`
+
+// todoSelect selects the columns read by scanTodo.
+const todoSelect = "SELECT " + todoColumns + " FROM todos"
+
+// projectIDQuery looks up the registered project whose directory and workspace
+// names are its two parameters. It yields NULL for a project that has not been
+// registered yet; the task is linked once it is (see registry.SaveProject).
+const projectIDQuery = `(SELECT p.id FROM projects p JOIN workspaces w ON w.id = p.workspace_id
+	WHERE p.dir = ? AND w.name = ?)`
+
+// SQLiteTodoService is an implementation of TodoService that keeps the live
+// tasks of a project in the "todos" table of the SQLite database instead of a
+// todo.md file. Tasks are scoped by project and workspace name.
+type SQLiteTodoService struct {
+	db            *sql.DB
+	projectName   string
+	workspaceName string
+}
+
+// NewSQLiteTodoService creates a SQLiteTodoService for the project in projectDir,
+// applying pending schema migrations if necessary. The project and workspace
+// names are the base names of projectDir and its parent directory.
+func NewSQLiteTodoService(conn *sql.DB, projectDir string) (*SQLiteTodoService, error) {
+	if _, err := migrate.Apply(conn); err != nil {
+		return nil, err
+	}
+	projectDir = filepath.Clean(projectDir)
+	return &SQLiteTodoService{
+		db:            conn,
+		projectName:   filepath.Base(projectDir),
+		workspaceName: filepath.Base(filepath.Dir(projectDir)),
+	}, nil
+}
+
+// AddTodo creates a new task at the top of the project's list and returns its ID.
+// dueDate and priority accept the same input as FileTodoService.AddTodo.
+func (s *SQLiteTodoService) AddTodo(description, dueDate, priority string) (string, error) {
+	p, err := ParsePriority(priority)
+	if priority != "" && err != nil {
+		return "", err
+	}
+	dueDate, err = NormalizeDue(dueDate)
+	if err != nil {
+		return "", err
+	}
+
+	ids, err := s.ids()
+	if err != nil {
+		return "", err
+	}
+
+	// Parse the same task line the file store would write so inline tags
+	// such as "#every" or "#blocked-by" in the description are honoured.
+	taskLine := buildTaskLine(newTodoID(ids), description, dueDate, p, s.projectName, s.workspaceName)
+	t, err := parseTodo(taskLine)
+	if err != nil {
+		return "", fmt.Errorf("invalid task: %w", err)
+	}
+
+	var position int
+	if err := s.db.QueryRow("SELECT COALESCE(MIN(position), 1) - 1 FROM todos WHERE "+s.scope(),
+		s.projectName, s.workspaceName).Scan(&position); err != nil {
+		return "", fmt.Errorf("error adding task: %w", err)
+	}
+	if err := s.insert(t, position); err != nil {
+		return "", err
+	}
+	return t.ID, nil
+}
+
+// ListTodos returns the open and completed, not yet archived tasks of the
+// project, with subtasks following their parent. Tasks waiting for an open task
+// of the workspace are marked as blocked.
+func (s *SQLiteTodoService) ListTodos() ([]Todo, error) {
+	todos, err := s.query(todoSelect+" WHERE "+s.scope()+" ORDER BY position, id", s.projectName, s.workspaceName)
+	if err != nil {
+		return nil, err
+	}
+	todos = treeOrder(todos)
+
+	pool, err := s.query(todoSelect+" WHERE workspace_name = ? AND status != ?", s.workspaceName, statusArchived)
+	if err != nil {
+		return nil, err
+	}
+	MarkBlocked(todos, pool)
+	return todos, nil
+}
+
+// EditTodo updates the description, due date, status and/or priority of the task at the given index.
+func (s *SQLiteTodoService) EditTodo(index int, newDescription, newDueDate, newStatus, newPriority string) error {
+	id, err := s.idAt(index)
+	if err != nil {
+		return err
+	}
+	return s.EditTodoByID(id, newDescription, newDueDate, newStatus, newPriority)
+}
+
+// EditTodoByID updates the description, due date, status and/or priority of the task with the given ID.
+// Empty values leave the corresponding field unchanged; a priority of "0" or "none" clears it.
+func (s *SQLiteTodoService) EditTodoByID(id, newDescription, newDueDate, newStatus, newPriority string) error {
+	t, err := s.get(id)
+	if err != nil {
+		return err
+	}
+
+	completing, err := applyEdit(&t, newDescription, newDueDate, newStatus, newPriority)
+	if err != nil {
+		return err
+	}
+	if completing {
+		return s.complete(t)
+	}
+	return s.update(t)
+}
+
+// DeleteTodo removes the task at the given index.
+func (s *SQLiteTodoService) DeleteTodo(index int) error {
+	id, err := s.idAt(index)
+	if err != nil {
+		return err
+	}
+	return s.DeleteTodoByID(id)
+}
+
+// DeleteTodoByID removes the task with the given ID along with its subtasks.
+func (s *SQLiteTodoService) DeleteTodoByID(id string) error {
+	t, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	todos, err := s.ListTodos()
+	if err != nil {
+		return err
+	}
+
+	remove := append([]Todo{t}, descendants(todos, t.ID)...)
+	for _, r := range remove {
+		if _, err := s.db.Exec("DELETE FROM todos WHERE task_id = ? AND "+s.scope(),
+			r.ID, s.projectName, s.workspaceName); err != nil {
+			return fmt.Errorf("error deleting task: %w", err)
+		}
+	}
+	return nil
+}
+
+// CompleteTodo marks the task at the given index as completed.
+func (s *SQLiteTodoService) CompleteTodo(index int) error {
+	id, err := s.idAt(index)
+	if err != nil {
+		return err
+	}
+	return s.CompleteTodoByID(id)
+}
+
+// CompleteTodoByID marks the task with the given ID as completed. For a
+// recurring task the next occurrence is added right after it.
+func (s *SQLiteTodoService) CompleteTodoByID(id string) error {
+	t, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	if !t.CompletedDate.IsZero() {
+		return fmt.Errorf("task already completed")
+	}
+	t.CompletedDate = time.Now()
+	t.Ongoing = false
+	return s.complete(t)
+}
+
+// SetNotesByID replaces the notes of the task with the given ID.
+func (s *SQLiteTodoService) SetNotesByID(id string, notes []string) error {
+	t, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	t.Notes = notes
+	return s.update(t)
+}
+
+// ArchiveFinished marks the finished tasks selected by policy at now (see
+// selectArchivable) as archived, which removes them from ListTodos while
+// keeping them in the database. It returns the archived tasks.
+func (s *SQLiteTodoService) ArchiveFinished(policy config.ArchivePolicy, now time.Time) ([]Todo, error) {
+	// Open tasks are loaded too, as they keep their finished parents.
+	todos, err := s.query(todoSelect+" WHERE "+s.scope(), s.projectName, s.workspaceName)
+	if err != nil {
+		return nil, err
+	}
+	selected := selectArchivable(todos, policy, now)
+	if len(selected) == 0 {
+		return nil, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var archived []Todo
+	for i, t := range todos {
+		if !selected[i] {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE todos SET status = ? WHERE task_id = ? AND "+s.scope(),
+			statusArchived, t.ID, s.projectName, s.workspaceName); err != nil {
+			return nil, fmt.Errorf("error archiving tasks: %w", err)
+		}
+		archived = append(archived, t)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error archiving tasks: %w", err)
+	}
+	return archived, nil
+}
+
+// complete stores the completed task t and, if it recurs, inserts its next
+// occurrence right after it.
+func (s *SQLiteTodoService) complete(t Todo) error {
+	if t.Recur == "" {
+		return s.update(t)
+	}
+
+	t.Series = seriesOf(t)
+	ids, err := s.ids()
+	if err != nil {
+		return err
+	}
+	next, err := nextOccurrence(t, ids)
+	if err != nil {
+		return err
+	}
+	if err := s.update(t); err != nil {
+		return err
+	}
+
+	var position int
+	if err := s.db.QueryRow("SELECT position FROM todos WHERE task_id = ? AND "+s.scope(),
+		t.ID, s.projectName, s.workspaceName).Scan(&position); err != nil {
+		return fmt.Errorf("error scheduling next occurrence: %w", err)
+	}
+	return s.insert(next, position)
+}
+
+// scope returns the WHERE condition selecting the live rows of the project.
+// It expects the project and workspace names as arguments.
+func (s *SQLiteTodoService) scope() string {
+	return "project_name = ? AND workspace_name = ? AND status != '" + statusArchived + "'"
+}
+
+// get returns the live task with the given ID.
+func (s *SQLiteTodoService) get(id string) (Todo, error) {
+	todos, err := s.ListTodos()
+	if err != nil {
+		return Todo{}, fmt.Errorf("error loading tasks: %w", err)
+	}
+	i := FindTodoByID(todos, id)
+	if i < 0 {
+		return Todo{}, fmt.Errorf("task '%s' not found", id)
+	}
+	return todos[i], nil
+}
+
+// idAt returns the ID of the task at the given index of ListTodos.
+func (s *SQLiteTodoService) idAt(index int) (string, error) {
+	todos, err := s.ListTodos()
+	if err != nil {
+		return "", fmt.Errorf("error loading tasks: %w", err)
+	}
+	if index < 0 || index >= len(todos) {
+		return "", fmt.Errorf("invalid task index")
+	}
+	return todos[index].ID, nil
+}
+
+// ids returns the set of task IDs stored in the database, archived ones included.
+func (s *SQLiteTodoService) ids() (map[string]bool, error) {
+	rows, err := s.db.Query("SELECT task_id FROM todos WHERE task_id IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("error loading task IDs: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// insert adds t as a live task of the project at the given position.
+func (s *SQLiteTodoService) insert(t Todo, position int) error {
+	query := `
+	INSERT INTO todos (task_id, description, status, ongoing, priority, completed_date, created_date, due_date,
+		due, recur, series, parent_id, blocked_by, tags, notes, project_name, workspace_name, position, project_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ` + projectIDQuery + `);
+	`
+	t.ProjectName, t.WorkspaceName = s.projectName, s.workspaceName
+	args := append([]interface{}{t.ID}, todoColumnValues(t, liveStatus(t))...)
+	args = append(args, t.ProjectName, t.WorkspaceName, position, t.ProjectName, t.WorkspaceName)
+	if _, err := s.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("error adding task: %w", err)
+	}
+	return nil
+}
+
+// update writes the fields of t to its row.
+func (s *SQLiteTodoService) update(t Todo) error {
+	query := `
+	UPDATE todos SET description = ?, status = ?, ongoing = ?, priority = ?, completed_date = ?, created_date = ?,
+		due_date = ?, due = ?, recur = ?, series = ?, parent_id = ?, blocked_by = ?, tags = ?, notes = ?
+	WHERE task_id = ? AND ` + s.scope()
+	args := append(todoColumnValues(t, liveStatus(t)), t.ID, s.projectName, s.workspaceName)
+	if _, err := s.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("error updating task: %w", err)
+	}
+	return nil
+}
+
+// query runs a todoSelect query and scans the resulting tasks.
+func (s *SQLiteTodoService) query(query string, args ...interface{}) ([]Todo, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var todos []Todo
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error loading tasks: %w", err)
+		}
+		todos = append(todos, t)
+	}
+	return todos, rows.Err()
+}
+
+// liveStatus returns the status of t as a live task.
+func liveStatus(t Todo) string {
+	if t.CompletedDate.IsZero() {
+		return statusOpen
+	}
+	return statusDone
+}
+
+// todoColumnValues returns the values of the columns description through notes
+// as written by insert and update, with the given status.
+func todoColumnValues(t Todo, status string) []interface{} {
+	var completedDate interface{}
+	if !t.CompletedDate.IsZero() {
+		completedDate = t.CompletedDate
+	}
+	var dueDate, due interface{}
+	if !t.DueDate.IsZero() {
+		dueDate = t.DueDate
+		due = formatDueTag(t)
+	}
+	createdDate := t.CreatedDate
+	if createdDate.IsZero() {
+		createdDate = time.Now()
+	}
+	return []interface{}{
+		t.Description, status, t.Ongoing, t.Priority, completedDate, createdDate, dueDate,
+		due, t.Recur, t.Series, t.ParentID, strings.Join(t.BlockedBy, ","), FormatTags(t),
+		strings.Join(t.Notes, "\n"),
+	}
+}
+
+// scanTodo reads a row of todoColumns. Columns selected before them are
+// scanned into prefix.
+func scanTodo(rows *sql.Rows, prefix ...interface{}) (Todo, error) {
+	var t Todo
+	var status string
+	var completedDate, dueDate sql.NullTime
+	var taskID, due, recur, series, parentID, blockedBy, tags, notes, project, workspace sql.NullString
+	dest := append(prefix, &taskID, &t.Description, &status, &t.Ongoing, &t.Priority, &completedDate, &t.CreatedDate,
+		&dueDate, &due, &recur, &series, &parentID, &blockedBy, &tags, &notes, &project, &workspace)
+	if err := rows.Scan(dest...); err != nil {
+		return t, err
+	}
+
+	t.ID = taskID.String
+	t.Archived = status == statusArchived
+	if completedDate.Valid {
+		t.CompletedDate = completedDate.Time
+	}
+	if due.String != "" {
+		d, hasTime, zone, err := parseDueTag(due.String)
+		if err != nil {
+			return t, err
+		}
+		t.DueDate, t.DueHasTime, t.DueZone = d, hasTime, zone
+	} else if dueDate.Valid {
+		// Rows archived by older versions only have the due date.
+		t.DueDate = dueDate.Time
+	}
+	t.Recur, t.Series, t.ParentID = recur.String, series.String, parentID.String
+	t.BlockedBy = parseIDList(blockedBy.String)
+	if notes.String != "" {
+		t.Notes = strings.Split(notes.String, "\n")
+	}
+	t.ProjectName, t.WorkspaceName = project.String, workspace.String
+
+	// The tags column holds labels, contexts and generic tags as written in a task line.
+	if tags.String != "" {
+		parsed, err := parseTodo("- [ ] " + tags.String)
+		if err == nil {
+			t.Labels, t.Contexts, t.Tags = parsed.Labels, parsed.Contexts, parsed.Tags
+		}
+	}
+	return t, nil
+}
+
+// treeOrder sorts todos so that every subtask directly follows its parent (or
+// an earlier sibling's subtree) and sets Depth accordingly. Tasks whose parent
+// is missing are treated as top-level tasks.
+func treeOrder(todos []Todo) []Todo {
+	present := make(map[string]bool)
+	for _, t := range todos {
+		present[t.ID] = true
+	}
+	children := make(map[string][]Todo)
+	for _, t := range todos {
+		parent := t.ParentID
+		if !present[parent] || parent == t.ID {
+			parent = ""
+		}
+		children[parent] = append(children[parent], t)
+	}
+
+	var ordered []Todo
+	var walk func(parent string, depth int)
+	walk = func(parent string, depth int) {
+		for _, t := range children[parent] {
+			t.Depth = depth
+			ordered = append(ordered, t)
+			walk(t.ID, depth+1)
+		}
+	}
+	walk("", 0)
+
+	// Tasks caught in a parent cycle are never reached; keep them at the top level.
+	if len(ordered) < len(todos) {
+		seen := make(map[string]bool)
+		for _, t := range ordered {
+			seen[t.ID] = true
+		}
+		for _, t := range todos {
+			if !seen[t.ID] {
+				t.Depth = 0
+				ordered = append(ordered, t)
+			}
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,97 @@
+package todo
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	db "github.com/johnjallday/flow-workspace/internal/db/todo"
+)
+
+// Names of the task stores a project can use.
+const (
+	StoreFile   = "file"   // tasks live in the project's todo.md
+	StoreSQLite = "sqlite" // tasks live in the todos table of the database
+)
+
+// StoreEnv is the environment variable that selects the store of every
+// project that doesn't set "todo_store" in its project_info.toml.
+const StoreEnv = "FLOW_TODO_STORE"
+
+// projectSettings are the task related settings of a project_info.toml.
+type projectSettings struct {
+	TodoStore string `toml:"todo_store"`
+	TodoSync  bool   `toml:"todo_sync"`
+
+	// Overrides of the default archive policy; nil when not set.
+	Archive          string `toml:"archive"`
+	ArchiveAfterDays *int   `toml:"archive_after_days"`
+	ArchiveKeepLast  *int   `toml:"archive_keep_last"`
+	ArchiveQuiet     *bool  `toml:"archive_quiet"`
+}
+
+// loadProjectSettings reads the settings of the project in projectDir. A
+// missing or unreadable project_info.toml yields the defaults.
+func loadProjectSettings(projectDir string) projectSettings {
+	var info projectSettings
+	_, _ = toml.DecodeFile(filepath.Join(projectDir, "project_info.toml"), &info)
+	return info
+}
+
+// ProjectStore returns the task store used by the project in projectDir: the
+// "todo_store" setting of its project_info.toml, otherwise the value of
+// StoreEnv, otherwise StoreFile.
+func ProjectStore(projectDir string) string {
+	store := strings.ToLower(strings.TrimSpace(loadProjectSettings(projectDir).TodoStore))
+	if store == "" {
+		store = strings.ToLower(strings.TrimSpace(os.Getenv(StoreEnv)))
+	}
+	if store == "" {
+		store = StoreFile
+	}
+	return store
+}
+
+// NewTodoService returns the TodoService for the project owning todoFilePath,
+// using the store selected by ProjectStore. The database conn is only used by
+// the SQLite store and stays owned by the caller, who closes it.
+func NewTodoService(conn *sql.DB, todoFilePath string) (TodoService, error) {
+	projectDir := filepath.Dir(todoFilePath)
+	switch store := ProjectStore(projectDir); store {
+	case StoreFile:
+		return NewFileTodoService(todoFilePath), nil
+	case StoreSQLite:
+		return NewSQLiteTodoService(conn, projectDir)
+	default:
+		return nil, fmt.Errorf("unknown todo store '%s' (use %s or %s)", store, StoreFile, StoreSQLite)
+	}
+}
+
+// ProjectSync reports whether the project in projectDir has "todo_sync"
+// enabled, i.e. its todo.md and its tasks in the database are synced
+// automatically by the REPLs.
+func ProjectSync(projectDir string) bool {
+	return loadProjectSettings(projectDir).TodoSync
+}
+
+// LoadProjectTodos returns the tasks of the project in projectDir from
+// whichever store it uses.
+func LoadProjectTodos(dbPath, projectDir string) ([]Todo, error) {
+	todoFile := filepath.Join(projectDir, "todo.md")
+	if ProjectStore(projectDir) == StoreFile {
+		return LoadAllTodos(todoFile)
+	}
+	conn, err := db.InitDB(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to db: %w", err)
+	}
+	defer conn.Close()
+	service, err := NewTodoService(conn, todoFile)
+	if err != nil {
+		return nil, err
+	}
+	return service.ListTodos()
+}
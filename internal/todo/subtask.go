@@ -0,0 +1,120 @@
+package todo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/johnjallday/flow-workspace/internal/shell"
+)
+
+// Subtasks returns the direct children of the task with the given ID.
+func Subtasks(todos []Todo, id string) []Todo {
+	var children []Todo
+	for _, t := range todos {
+		if t.ParentID != "" && t.ParentID == id {
+			children = append(children, t)
+		}
+	}
+	return children
+}
+
+// descendants returns all subtasks of the task with the given ID, at any depth.
+// todos must be in file order, so that parents come before their children.
+func descendants(todos []Todo, id string) []Todo {
+	inTree := map[string]bool{id: true}
+	var result []Todo
+	for _, t := range todos {
+		if t.ParentID != "" && inTree[t.ParentID] {
+			inTree[t.ID] = true
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// taskKey returns the key of the task with the given ID among tasks of the
+// same file as t. IDs are only unique within a file, so the key includes the
+// project and workspace of aggregated lists.
+func taskKey(t Todo, id string) string {
+	return t.WorkspaceName + "/" + t.ProjectName + "/" + id
+}
+
+// progress is the number of completed subtasks of a task and the total number
+// of its subtasks, at any depth.
+type progress struct {
+	done, total int
+}
+
+// subtaskProgress returns the progress of every task of todos that has
+// subtasks, by taskKey.
+func subtaskProgress(todos []Todo) map[string]progress {
+	parents := make(map[string]string) // parent key by task key
+	for _, t := range todos {
+		if t.ParentID != "" {
+			parents[taskKey(t, t.ID)] = taskKey(t, t.ParentID)
+		}
+	}
+	result := make(map[string]progress)
+	for _, t := range todos {
+		if t.ParentID == "" {
+			continue
+		}
+		// Count the task for each of its ancestors; the walk is bounded in
+		// case the stored parents form a cycle.
+		parent := taskKey(t, t.ParentID)
+		for n := 0; parent != "" && n < len(todos); n++ {
+			p := result[parent]
+			p.total++
+			if !t.CompletedDate.IsZero() {
+				p.done++
+			}
+			result[parent] = p
+			parent = parents[parent]
+		}
+	}
+	return result
+}
+
+// OpenSubtasks returns the unfinished subtasks of the task with the given ID, at any depth.
+func OpenSubtasks(todos []Todo, id string) []Todo {
+	var open []Todo
+	for _, t := range descendants(todos, id) {
+		if t.CompletedDate.IsZero() {
+			open = append(open, t)
+		}
+	}
+	return open
+}
+
+// PromptCompleteSubtasks asks what to do with the open subtasks of a task that
+// is about to be completed. It returns the IDs of the subtasks to complete along
+// with the task itself, and false if the user cancelled.
+func PromptCompleteSubtasks(sh *shell.Shell, todos []Todo, id string) ([]string, bool) {
+	open := OpenSubtasks(todos, id)
+	if len(open) == 0 {
+		return nil, true
+	}
+
+	fmt.Printf("This task has %d open subtask(s):\n", len(open))
+	for _, t := range open {
+		fmt.Printf("  - %s\n", t.Description)
+	}
+	answer, err := sh.Ask("Complete them as well? (y/n/cancel): ")
+	if err != nil {
+		fmt.Println("Error reading input:", err)
+		return nil, false
+	}
+	switch strings.TrimSpace(strings.ToLower(answer)) {
+	case "y", "yes":
+		var ids []string
+		for _, t := range open {
+			ids = append(ids, t.ID)
+		}
+		return ids, true
+	case "n", "no":
+		return nil, true
+	default:
+		fmt.Println("Completion canceled.")
+		return nil, false
+	}
+}
@@ -0,0 +1,74 @@
+package todo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubtaskProgress(t *testing.T) {
+	done := date("2026-01-02")
+	tests := []struct {
+		name  string
+		todos []Todo
+		want  map[string]progress
+	}{
+		{
+			name:  "no subtasks",
+			todos: []Todo{{ID: "a"}, {ID: "b", CompletedDate: done}},
+			want:  map[string]progress{},
+		},
+		{
+			name: "nested subtasks count for every ancestor",
+			todos: []Todo{
+				{ID: "p"},
+				{ID: "c1", ParentID: "p", Depth: 1, CompletedDate: done},
+				{ID: "c2", ParentID: "p", Depth: 1},
+				{ID: "g", ParentID: "c2", Depth: 2, CompletedDate: done},
+			},
+			want: map[string]progress{
+				"//p":  {done: 2, total: 3},
+				"//c2": {done: 1, total: 1},
+			},
+		},
+		{
+			name: "subtasks listed before their parent",
+			todos: []Todo{
+				{ID: "c", ParentID: "p", Depth: 1, CompletedDate: done},
+				{ID: "p"},
+			},
+			want: map[string]progress{"//p": {done: 1, total: 1}},
+		},
+		{
+			// IDs are only unique within a file, so the same ID in another
+			// project is another task.
+			name: "same IDs in two projects",
+			todos: []Todo{
+				{ID: "p", ProjectName: "one", WorkspaceName: "ws"},
+				{ID: "c", ParentID: "p", Depth: 1, ProjectName: "one", WorkspaceName: "ws", CompletedDate: done},
+				{ID: "p", ProjectName: "two", WorkspaceName: "ws"},
+				{ID: "c", ParentID: "p", Depth: 1, ProjectName: "two", WorkspaceName: "ws"},
+				{ID: "d", ParentID: "p", Depth: 1, ProjectName: "two", WorkspaceName: "ws"},
+			},
+			want: map[string]progress{
+				"ws/one/p": {done: 1, total: 1},
+				"ws/two/p": {done: 0, total: 2},
+			},
+		},
+		{
+			name: "parents forming a cycle",
+			todos: []Todo{
+				{ID: "a", ParentID: "b"},
+				{ID: "b", ParentID: "a"},
+			},
+			want: map[string]progress{
+				"//a": {done: 0, total: 2},
+				"//b": {done: 0, total: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := subtaskProgress(tt.todos); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: subtaskProgress = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
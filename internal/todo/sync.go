@@ -0,0 +1,294 @@
+package todo
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/johnjallday/flow-workspace/internal/shell"
+)
+
+// Resolutions of a sync conflict, as returned by a SyncResolver.
+const (
+	SyncKeepFile = "file" // overwrite the database with the todo.md version
+	SyncKeepDB   = "db"   // overwrite todo.md with the database version
+	SyncSkip     = ""     // leave both sides as they are until the next sync
+)
+
+// SyncConflict is a task that changed both in todo.md and in the database
+// since the last sync. File or DB is nil if the task was deleted on that side.
+type SyncConflict struct {
+	ID   string
+	File *Todo
+	DB   *Todo
+}
+
+// SyncResolver decides how a conflict is resolved and returns SyncKeepFile,
+// SyncKeepDB or SyncSkip.
+type SyncResolver func(c SyncConflict) string
+
+// SyncResult summarizes what SyncProject changed.
+type SyncResult struct {
+	ToDB      int            // tasks added to or updated in the database
+	ToFile    int            // tasks added to or updated in todo.md
+	Deleted   int            // tasks deleted on one side because they were deleted on the other
+	Conflicts []SyncConflict // conflicts that were skipped
+}
+
+// SyncProject brings the todo file at todoFilePath and the live tasks of its
+// project in the database in line with each other. Tasks are matched by ID and
+// compared with the version recorded at the last sync: a task that changed on
+// one side only is copied to the other side, a task deleted on one side and
+// unchanged on the other is deleted there too, and new tasks are copied over.
+// Tasks that changed on both sides are passed to resolve; if resolve is nil or
+// returns SyncSkip they are left alone and reported in the result.
+//
+// The project and workspace a task belongs to and its nesting are not synced:
+// they are taken from the side that created the task.
+func SyncProject(conn *sql.DB, todoFilePath string, resolve SyncResolver) (SyncResult, error) {
+	var result SyncResult
+
+	service, err := NewSQLiteTodoService(conn, filepath.Dir(todoFilePath))
+	if err != nil {
+		return result, err
+	}
+
+	doc, err := LoadDocument(todoFilePath)
+	if os.IsNotExist(err) {
+		doc = ParseDocument("# todo\n")
+	} else if err != nil {
+		return result, fmt.Errorf("error loading '%s': %w", todoFilePath, err)
+	}
+	fileTodos := doc.Todos()
+	dbTodos, err := service.ListTodos()
+	if err != nil {
+		return result, err
+	}
+	base, err := service.syncHashes()
+	if err != nil {
+		return result, err
+	}
+
+	fileByID := make(map[string]*Todo)
+	for i := range fileTodos {
+		fileByID[fileTodos[i].ID] = &fileTodos[i]
+	}
+	dbByID := make(map[string]*Todo)
+	for i := range dbTodos {
+		dbByID[dbTodos[i].ID] = &dbTodos[i]
+	}
+
+	// Visit every task known to either side or to the last sync, file order first.
+	var ids []string
+	seen := make(map[string]bool)
+	for _, list := range [][]Todo{fileTodos, dbTodos} {
+		for _, t := range list {
+			if !seen[t.ID] {
+				seen[t.ID] = true
+				ids = append(ids, t.ID)
+			}
+		}
+	}
+	for id := range base {
+		if !seen[id] {
+			ids = append(ids, id)
+		}
+	}
+
+	// Changes to the file are collected and written with a single Replace.
+	fileResult := make(map[string]*Todo)
+	for _, t := range fileTodos {
+		t := t
+		fileResult[t.ID] = &t
+	}
+	var newInFile []Todo
+	hashes := make(map[string]string)
+
+	for _, id := range ids {
+		f, d := fileByID[id], dbByID[id]
+		b, synced := base[id]
+
+		// Work out which side changed since the last sync.
+		fileChanged := f != nil && (!synced || syncHash(*f) != b) || f == nil && synced
+		dbChanged := d != nil && (!synced || syncHash(*d) != b) || d == nil && synced
+
+		keep := ""
+		switch {
+		case f == nil && d == nil:
+			// Deleted on both sides.
+		case f != nil && d != nil && syncHash(*f) == syncHash(*d):
+			hashes[id] = syncHash(*f)
+			continue
+		case fileChanged && !dbChanged:
+			keep = SyncKeepFile
+		case dbChanged && !fileChanged:
+			keep = SyncKeepDB
+		default:
+			c := SyncConflict{ID: id, File: f, DB: d}
+			if resolve != nil {
+				keep = resolve(c)
+			}
+			if keep == SyncSkip {
+				result.Conflicts = append(result.Conflicts, c)
+				if synced {
+					hashes[id] = b
+				}
+				continue
+			}
+		}
+
+		switch {
+		case keep == SyncKeepFile && f == nil:
+			if err := service.deleteRow(id); err != nil {
+				return result, err
+			}
+			result.Deleted++
+		case keep == SyncKeepFile && d == nil:
+			// The database requires a creation date; write the same one to the file.
+			t := *f
+			if t.CreatedDate.IsZero() {
+				t.CreatedDate, _ = time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+				fileResult[id] = &t
+			}
+			if err := service.insert(t, FindTodoByID(fileTodos, id)); err != nil {
+				return result, err
+			}
+			hashes[id] = syncHash(t)
+			result.ToDB++
+		case keep == SyncKeepFile:
+			if err := service.update(*f); err != nil {
+				return result, err
+			}
+			hashes[id] = syncHash(*f)
+			result.ToDB++
+		case keep == SyncKeepDB && d == nil:
+			delete(fileResult, id)
+			result.Deleted++
+		case keep == SyncKeepDB && f == nil:
+			newInFile = append(newInFile, *d)
+			hashes[id] = syncHash(*d)
+			result.ToFile++
+		case keep == SyncKeepDB:
+			// Keep the file's own project and workspace tags.
+			t := *d
+			t.ProjectName, t.WorkspaceName = f.ProjectName, f.WorkspaceName
+			fileResult[id] = &t
+			hashes[id] = syncHash(*d)
+			result.ToFile++
+		}
+	}
+
+	var todos []Todo
+	for _, t := range fileTodos {
+		if r, ok := fileResult[t.ID]; ok {
+			todos = append(todos, *r)
+		}
+	}
+	doc.Replace(append(todos, newInFile...))
+	if doc.Modified() || len(todos) != len(fileTodos) {
+		if err := doc.Save(todoFilePath); err != nil {
+			return result, err
+		}
+	}
+
+	return result, service.saveSyncHashes(hashes)
+}
+
+// PromptSyncConflict is a SyncResolver that shows both versions of a task and
+// asks which one to keep.
+func PromptSyncConflict(sh *shell.Shell) SyncResolver {
+	return func(c SyncConflict) string {
+		fmt.Printf("Task %s changed in todo.md and in the database since the last sync.\n", c.ID)
+		fmt.Println("  todo.md: ", describeSyncSide(c.File))
+		fmt.Println("  database:", describeSyncSide(c.DB))
+		answer, _ := sh.Ask("Keep which version? (file/db, leave empty to skip): ")
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "file", "f":
+			return SyncKeepFile
+		case "db", "d", "database":
+			return SyncKeepDB
+		default:
+			return SyncSkip
+		}
+	}
+}
+
+// PrintSyncResult prints a short summary of a sync.
+func PrintSyncResult(result SyncResult) {
+	fmt.Printf("Sync finished: %d task(s) written to the database, %d to todo.md, %d deleted.\n",
+		result.ToDB, result.ToFile, result.Deleted)
+	for _, c := range result.Conflicts {
+		fmt.Printf("Conflict left unresolved for task %s.\n", c.ID)
+	}
+}
+
+// describeSyncSide returns the task line of one side of a conflict.
+func describeSyncSide(t *Todo) string {
+	if t == nil {
+		return "(deleted)"
+	}
+	return formatTodo(*t)
+}
+
+// syncHash returns a hash of the parts of a task that are synced.
+func syncHash(t Todo) string {
+	t.ProjectName, t.WorkspaceName = "", ""
+	sum := sha256.Sum256([]byte(formatTodo(t) + "\n" + strings.Join(t.Notes, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// syncHashes returns the task hashes recorded for the project at the last sync.
+func (s *SQLiteTodoService) syncHashes() (map[string]string, error) {
+	rows, err := s.db.Query("SELECT task_id, hash FROM sync_state WHERE project_name = ? AND workspace_name = ?",
+		s.projectName, s.workspaceName)
+	if err != nil {
+		return nil, fmt.Errorf("error loading sync state: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var id, hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return nil, err
+		}
+		hashes[id] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// saveSyncHashes replaces the recorded sync state of the project with hashes.
+func (s *SQLiteTodoService) saveSyncHashes(hashes map[string]string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sync_state WHERE project_name = ? AND workspace_name = ?",
+		s.projectName, s.workspaceName); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error saving sync state: %w", err)
+	}
+	now := time.Now()
+	for id, hash := range hashes {
+		if _, err := tx.Exec("INSERT INTO sync_state (workspace_name, project_name, task_id, hash, synced_at) VALUES (?, ?, ?, ?, ?)",
+			s.workspaceName, s.projectName, id, hash, now); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error saving sync state: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// deleteRow removes the live task with the given ID, leaving its subtasks.
+func (s *SQLiteTodoService) deleteRow(id string) error {
+	if _, err := s.db.Exec("DELETE FROM todos WHERE task_id = ? AND "+s.scope(),
+		id, s.projectName, s.workspaceName); err != nil {
+		return fmt.Errorf("error deleting task: %w", err)
+	}
+	return nil
+}
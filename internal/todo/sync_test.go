@@ -0,0 +1,238 @@
+package todo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// syncFixture is a project with a todo.md and a database that have been
+// synced once with the tasks aaaa11 "a" and bbbb22 "b".
+type syncFixture struct {
+	t        *testing.T
+	todoFile string
+	service  *SQLiteTodoService
+}
+
+func newSyncFixture(t *testing.T) *syncFixture {
+	t.Helper()
+	conn := openTestDB(t)
+	projectDir := filepath.Join(t.TempDir(), "ws", "proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	service, err := NewSQLiteTodoService(conn, projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &syncFixture{t: t, todoFile: filepath.Join(projectDir, "todo.md"), service: service}
+	f.writeFile("# todo\n\n- [ ] a #created:2026-01-01 #id:aaaa11\n- [ ] b #created:2026-01-01 #id:bbbb22\n")
+	if result := f.sync(nil); result.ToDB != 2 {
+		t.Fatalf("initial sync copied %d tasks to the database, want 2", result.ToDB)
+	}
+	return f
+}
+
+func (f *syncFixture) writeFile(content string) {
+	f.t.Helper()
+	if err := WriteFileContent(f.todoFile, content); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// editFile replaces old with new in todo.md.
+func (f *syncFixture) editFile(old, new string) {
+	f.t.Helper()
+	content, err := ReadFileContent(f.todoFile)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if !strings.Contains(content, old) {
+		f.t.Fatalf("todo.md has no %q:\n%s", old, content)
+	}
+	f.writeFile(strings.Replace(content, old, new, 1))
+}
+
+func (f *syncFixture) sync(resolve SyncResolver) SyncResult {
+	f.t.Helper()
+	result, err := SyncProject(f.service.db, f.todoFile, resolve)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return result
+}
+
+// descriptions returns the descriptions of the tasks in todo.md and in the
+// database, by ID.
+func (f *syncFixture) descriptions() (file, db map[string]string) {
+	f.t.Helper()
+	fileTodos, err := LoadAllTodos(f.todoFile)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	dbTodos, err := f.service.ListTodos()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	file, db = make(map[string]string), make(map[string]string)
+	for _, t := range fileTodos {
+		file[t.ID] = t.Description
+	}
+	for _, t := range dbTodos {
+		db[t.ID] = t.Description
+	}
+	return file, db
+}
+
+func TestSyncProject(t *testing.T) {
+	keep := func(side string) SyncResolver {
+		return func(SyncConflict) string { return side }
+	}
+	tests := []struct {
+		name      string
+		change    func(f *syncFixture)
+		resolve   SyncResolver
+		want      SyncResult
+		conflicts int
+		wantA     string // description of aaaa11 on both sides, "" if deleted
+	}{
+		{
+			name:   "no changes",
+			change: func(f *syncFixture) {},
+			wantA:  "a",
+		},
+		{
+			name:   "changed in file",
+			change: func(f *syncFixture) { f.editFile("- [ ] a ", "- [ ] a2 ") },
+			want:   SyncResult{ToDB: 1},
+			wantA:  "a2",
+		},
+		{
+			name: "changed in database",
+			change: func(f *syncFixture) {
+				if err := f.service.EditTodoByID("aaaa11", "a3", "", "", ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:  SyncResult{ToFile: 1},
+			wantA: "a3",
+		},
+		{
+			name: "changed alike on both sides",
+			change: func(f *syncFixture) {
+				f.editFile("- [ ] a ", "- [ ] same ")
+				if err := f.service.EditTodoByID("aaaa11", "same", "", "", ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantA: "same",
+		},
+		{
+			name:    "conflict kept from file",
+			change:  bothChanged,
+			resolve: keep(SyncKeepFile),
+			want:    SyncResult{ToDB: 1},
+			wantA:   "from file",
+		},
+		{
+			name:    "conflict kept from database",
+			change:  bothChanged,
+			resolve: keep(SyncKeepDB),
+			want:    SyncResult{ToFile: 1},
+			wantA:   "from db",
+		},
+		{
+			name:      "conflict skipped",
+			change:    bothChanged,
+			resolve:   keep(SyncSkip),
+			conflicts: 1,
+		},
+		{
+			name:      "conflict without resolver",
+			change:    bothChanged,
+			conflicts: 1,
+		},
+		{
+			name:   "deleted in file",
+			change: func(f *syncFixture) { f.editFile("- [ ] a #created:2026-01-01 #id:aaaa11\n", "") },
+			want:   SyncResult{Deleted: 1},
+		},
+		{
+			name: "deleted in database",
+			change: func(f *syncFixture) {
+				if err := f.service.DeleteTodoByID("aaaa11"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: SyncResult{Deleted: 1},
+		},
+		{
+			name: "deleted in file and changed in database",
+			change: func(f *syncFixture) {
+				f.editFile("- [ ] a #created:2026-01-01 #id:aaaa11\n", "")
+				if err := f.service.EditTodoByID("aaaa11", "from db", "", "", ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+			resolve: keep(SyncKeepDB),
+			want:    SyncResult{ToFile: 1},
+			wantA:   "from db",
+		},
+		{
+			name: "added on both sides",
+			change: func(f *syncFixture) {
+				f.editFile("- [ ] b ", "- [ ] c #id:cccc33\n- [ ] b ")
+				if _, err := f.service.AddTodo("d", "", ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:  SyncResult{ToDB: 1, ToFile: 1},
+			wantA: "a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newSyncFixture(t)
+			tt.change(f)
+			result := f.sync(tt.resolve)
+			if len(result.Conflicts) != tt.conflicts {
+				t.Errorf("%d conflicts, want %d", len(result.Conflicts), tt.conflicts)
+			}
+			if result.ToDB != tt.want.ToDB || result.ToFile != tt.want.ToFile || result.Deleted != tt.want.Deleted {
+				t.Errorf("result = %+v, want %+v", result, tt.want)
+			}
+
+			file, db := f.descriptions()
+			if tt.conflicts == 0 {
+				if len(file) != len(db) {
+					t.Errorf("file has %v, database has %v", file, db)
+				}
+				for id, desc := range file {
+					if db[id] != desc {
+						t.Errorf("task %s is %q in the file and %q in the database", id, desc, db[id])
+					}
+				}
+				if file["aaaa11"] != tt.wantA {
+					t.Errorf("task aaaa11 is %q, want %q", file["aaaa11"], tt.wantA)
+				}
+			} else if file["aaaa11"] != "from file" || db["aaaa11"] != "from db" {
+				t.Errorf("skipped conflict changed a side: file %q, database %q", file["aaaa11"], db["aaaa11"])
+			}
+
+			// A second sync has nothing left to do but the skipped conflicts.
+			again := f.sync(nil)
+			if len(again.Conflicts) != tt.conflicts || again.ToDB+again.ToFile+again.Deleted != 0 {
+				t.Errorf("second sync = %+v", again)
+			}
+		})
+	}
+}
+
+// bothChanged changes the task aaaa11 differently in the file and in the database.
+func bothChanged(f *syncFixture) {
+	f.editFile("- [ ] a ", "- [ ] from file ")
+	if err := f.service.EditTodoByID("aaaa11", "from db", "", "", ""); err != nil {
+		f.t.Fatal(err)
+	}
+}
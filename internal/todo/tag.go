@@ -1,9 +1,9 @@
 package todo
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 )
 
@@ -25,7 +25,6 @@ func tagWorkspace(projectPath string) string {
 	// Get the parent folder of projectPath.
 	workspacePath := filepath.Dir(projectPath)
 	// Get the folder one level above parentPath (i.e. two levels above projectPath).
-	fmt.Println(filepath.Base(workspacePath))
 	if _, err := os.Stat(workspacePath); err == nil {
 		return filepath.Base(workspacePath)
 	}
@@ -33,13 +32,16 @@ func tagWorkspace(projectPath string) string {
 }
 
 // buildTaskLine constructs and returns the complete task line including tags.
-// It appends any non-empty tags (due date, project, and workspace) along with
-// a creation date tag using the current date.
-func buildTaskLine(description, dueDate, projectName, workspaceName string) string {
+// It appends any non-empty tags (due date, priority, project, and workspace) along with
+// a creation date tag using the current date and the task's ID.
+func buildTaskLine(id, description, dueDate string, priority int, projectName, workspaceName string) string {
 	tags := ""
 	if dueDate != "" {
 		tags += " #due:" + dueDate
 	}
+	if priority != 0 {
+		tags += " #p:" + strconv.Itoa(priority)
+	}
 	if projectName != "" {
 		tags += " #project:" + projectName
 	}
@@ -48,6 +50,9 @@ func buildTaskLine(description, dueDate, projectName, workspaceName string) stri
 	}
 	// Append a created date tag using the current time.
 	tags += " #created:" + time.Now().Format("2006-01-02")
+	if id != "" {
+		tags += " #id:" + id
+	}
 
 	return "- [ ] " + description + tags
 }
@@ -1,14 +1,86 @@
 package todo
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Todo represents a single task.
 type Todo struct {
+	ID            string // stable identifier stored in the "#id" tag
 	Description   string
 	CompletedDate time.Time // non-zero means the task is complete
 	CreatedDate   time.Time
 	DueDate       time.Time
+	DueHasTime    bool   // true if DueDate carries a time of day
+	DueZone       string // zone the due time was given in: "", "UTC", an offset or an IANA name
 	ProjectName   string
 	WorkspaceName string
-	Ongoing       bool // true if the task is in progress
+	Ongoing       bool     // true if the task is in progress
+	Priority      int      // 1 (highest) to MaxPriority, 0 if unset
+	Recur         string   // recurrence rule from the "#every" tag, e.g. "weekly"
+	Series        string   // ID of the first occurrence of a recurring task
+	ParentID      string   // ID of the parent task, derived from indentation
+	Depth         int      // nesting level of the checklist item, 0 for top-level tasks
+	Notes         []string // indented lines written below the task
+	BlockedBy     []string // tasks that must be done first, "id" or "project/id", from "#blocked-by"
+	Blocked       bool     // true if a task in BlockedBy is still open, see MarkBlocked
+	Archived      bool     // true for a task read from the archive in the database
+	Tags          []Tag    // "#key:value" tags not interpreted above, in file order
+	Labels        []string // bare "#label" tags
+	Contexts      []string // "@context" markers
+}
+
+// Tag is a single "#key:value" tag on a task line.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// TagValue returns the value of the generic tag with the given key.
+func (t Todo) TagValue(key string) (string, bool) {
+	for _, tag := range t.Tags {
+		if strings.EqualFold(tag.Key, key) {
+			return tag.Value, true
+		}
+	}
+	return "", false
+}
+
+// SetTag sets a generic tag, replacing an existing tag with the same key.
+// An empty value removes the tag.
+func (t *Todo) SetTag(key, value string) {
+	for i, tag := range t.Tags {
+		if strings.EqualFold(tag.Key, key) {
+			if value == "" {
+				t.Tags = append(t.Tags[:i:i], t.Tags[i+1:]...)
+			} else {
+				t.Tags[i].Value = value
+			}
+			return
+		}
+	}
+	if value != "" {
+		t.Tags = append(t.Tags, Tag{Key: key, Value: value})
+	}
+}
+
+// HasLabel reports whether the task carries the given bare "#label".
+func (t Todo) HasLabel(label string) bool {
+	for _, l := range t.Labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasContext reports whether the task carries the given "@context".
+func (t Todo) HasContext(context string) bool {
+	for _, c := range t.Contexts {
+		if strings.EqualFold(c, context) {
+			return true
+		}
+	}
+	return false
 }
@@ -5,42 +5,66 @@ import (
 	"time"
 )
 
-// ReviewWeekly filters todos completed this week (Saturday to Friday)
-// and prints a report of both completed and unfinished tasks.
-// It also indicates if today is the review day (Friday).
-func ReviewWeekly(todos []Todo) {
-	now := time.Now()
+// WeeklyReview sorts tasks for the review of the current week, which runs
+// from the most recent weekStart to the review day six days later.
+type WeeklyReview struct {
+	Start      time.Time
+	End        time.Time
+	Completed  []Todo // completed within the review period
+	Unfinished []Todo
+	Overdue    []Todo // unfinished and past their deadline
+	DueSoon    []Todo // unfinished and due within DueSoonWindow
+	ReviewDay  bool   // true if today is the last day of the week
+}
+
+// NewWeeklyReview sorts todos for the review of the week containing now.
+func NewWeeklyReview(todos []Todo, weekStart time.Weekday, now time.Time) WeeklyReview {
 	// Reset today's time to midnight to simplify date comparisons.
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
-	// Compute a custom weekday index where Saturday is 0, Sunday is 1, ..., Friday is 6.
-	customWeekday := (int(now.Weekday()) + 1) % 7
-	// The review period starts on the most recent Saturday.
-	reviewStart := today.AddDate(0, 0, -customWeekday)
-	// The review period ends on Friday (6 days after Saturday).
-	reviewEnd := reviewStart.AddDate(0, 0, 6)
-
-	fmt.Printf("Review Period: %s to %s\n", reviewStart.Format("2006-01-02"), reviewEnd.Format("2006-01-02"))
-
-	var completed []Todo
-	var unfinished []Todo
+	// Compute a custom weekday index where weekStart is 0 and the review day is 6.
+	customWeekday := (int(now.Weekday()) - int(weekStart) + 7) % 7
+	var r WeeklyReview
+	// The review period starts on the most recent weekStart.
+	r.Start = today.AddDate(0, 0, -customWeekday)
+	// The review period ends on the review day (6 days after weekStart).
+	r.End = r.Start.AddDate(0, 0, 6)
+	r.ReviewDay = customWeekday == 6
 
 	// Iterate over todos and classify them.
 	for _, todo := range todos {
 		// A non-zero CompletedDate means the task is complete.
 		if !todo.CompletedDate.IsZero() {
 			// Check if the task was completed within the review period.
-			if !todo.CompletedDate.Before(reviewStart) && !todo.CompletedDate.After(reviewEnd) {
-				completed = append(completed, todo)
+			if !todo.CompletedDate.Before(r.Start) && !todo.CompletedDate.After(r.End) {
+				r.Completed = append(r.Completed, todo)
 			}
-		} else {
-			// The task is not yet completed.
-			unfinished = append(unfinished, todo)
+			continue
+		}
+		// The task is not yet completed. Deadlines are compared as moments in
+		// time, so a task due at 14:00 UTC is overdue from 14:00 UTC
+		// regardless of the local time zone.
+		r.Unfinished = append(r.Unfinished, todo)
+		if todo.IsOverdue(now) {
+			r.Overdue = append(r.Overdue, todo)
+		}
+		if todo.IsDueSoon(now) {
+			r.DueSoon = append(r.DueSoon, todo)
 		}
 	}
+	return r
+}
+
+// ReviewWeekly filters todos completed this week (weekStart to the day before
+// the next weekStart) and prints a report of both completed and unfinished
+// tasks. It also indicates if today is the review day, the last day of the week.
+func ReviewWeekly(todos []Todo, weekStart time.Weekday) {
+	r := NewWeeklyReview(todos, weekStart, time.Now())
+
+	fmt.Printf("Review Period: %s to %s\n", r.Start.Format("2006-01-02"), r.End.Format("2006-01-02"))
 
 	fmt.Println("\nCompleted Todos for this week:")
-	for _, t := range completed {
+	for _, t := range r.Completed {
 		fmt.Printf(" - %s (Completed on: %s, Project: %s, Workspace: %s)\n",
 			t.Description,
 			t.CompletedDate.Format("2006-01-02"),
@@ -49,16 +73,26 @@ func ReviewWeekly(todos []Todo) {
 	}
 
 	fmt.Println("\nUnfinished Todos:")
-	for _, t := range unfinished {
+	for _, t := range r.Unfinished {
 		fmt.Printf(" - %s (Due: %s, Project: %s, Workspace: %s)\n",
 			t.Description,
-			t.DueDate.Format("2006-01-02"),
+			FormatDue(t),
 			t.ProjectName,
 			t.WorkspaceName)
 	}
 
-	// Check if today is the review day (Friday).
-	if now.Weekday() == time.Friday {
+	fmt.Println("\nOverdue Todos:")
+	for _, t := range r.Overdue {
+		fmt.Printf(" - %s (Due: %s)\n", t.Description, FormatDue(t))
+	}
+
+	fmt.Printf("\nDue within the next %d hours:\n", int(DueSoonWindow.Hours()))
+	for _, t := range r.DueSoon {
+		fmt.Printf(" - %s (Due: %s)\n", t.Description, FormatDue(t))
+	}
+
+	// Check if today is the review day.
+	if r.ReviewDay {
 		fmt.Println("\nToday is review day!")
 	} else {
 		fmt.Println("\nToday is not review day.")
@@ -2,24 +2,25 @@
 package workspace
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/johnjallday/flow-workspace/internal/config"
+	db "github.com/johnjallday/flow-workspace/internal/db/todo"
 	"github.com/johnjallday/flow-workspace/internal/project"
+	"github.com/johnjallday/flow-workspace/internal/search"
+	"github.com/johnjallday/flow-workspace/internal/shell"
+	"github.com/johnjallday/flow-workspace/internal/todo"
 )
 
 // StartWorkspaceREPL starts an interactive REPL for the specified workspace directory.
-func StartWorkspaceREPL(dbPath string, workspaceDir string) {
-	reader := bufio.NewReader(os.Stdin)
+func StartWorkspaceREPL(cfg *config.Config, workspaceDir string) {
 	// Extract the base folder name from workspaceDir.
 	currentWorkspace := filepath.Base(workspaceDir)
 	fmt.Printf("Workspace REPL started for directory: %s\n", workspaceDir)
 	fmt.Printf("Current Workspace: %s\n", currentWorkspace)
-	printWorkspaceHelp()
 
 	// Attempt to load the workspace's projects.toml
 	projs, err := LoadProjectsToml(workspaceDir)
@@ -29,97 +30,154 @@ func StartWorkspaceREPL(dbPath string, workspaceDir string) {
 		projs = &Projects{}
 	}
 
-	for {
-		fmt.Printf("\n[workspace:%s] >> ", currentWorkspace)
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Println("Error reading input:", err)
-			return
-		}
-		line = strings.TrimSpace(line)
-
-		switch strings.ToLower(line) {
-		case "exit":
-			fmt.Println("Exiting Workspace REPL. Goodbye!")
-			return
-
-		case "help":
-			printWorkspaceHelp()
-
-		case "list projects":
-			// Display the projects using your existing ListProjects() function
-			ListProjects(projs)
-
-		case "todo":
-			// Lists aggregated TODOs for all projects in this workspace.
-			// Note: we now call the function from the new aggregated workspace todo package.
-			ListAllTodos(workspaceDir)
-
-		case "select project":
-			// Let the user choose a project by number, then start the Project REPL
-			selectProject(dbPath, workspaceDir, projs, reader)
-
-		case "update projects":
-			// Update projects by scanning the workspace.
-			updatedProjs, err := UpdateProjects(workspaceDir)
-			if err != nil {
-				fmt.Printf("Error updating projects: %v\n", err)
-			} else {
+	sh := shell.New("Workspace REPL", "workspace", fmt.Sprintf("\n[workspace:%s] >> ", currentWorkspace))
+	sh.Register(
+		&shell.Command{
+			Name:    "list projects",
+			Aliases: []string{"projects"},
+			Help:    "List all projects in this workspace",
+			Run: func(c *shell.Context) error {
+				ListProjects(projs)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "todo",
+			Help: "List aggregated TODOs from all projects in this workspace",
+			Run: func(c *shell.Context) error {
+				ListAllTodos(cfg, workspaceDir, "")
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "filter",
+			Args: "[filter]",
+			Help: "List aggregated TODOs matching #label, @context, key:value or text",
+			Run: func(c *shell.Context) error {
+				filter, err := c.Arg("Enter filter (#label @context key:value text): ")
+				if err != nil {
+					return err
+				}
+				ListAllTodos(cfg, workspaceDir, filter)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "archive",
+			Help: "Browse archived TODOs of this workspace and restore one",
+			Run: func(c *shell.Context) error {
+				browseArchive(cfg, workspaceDir, c.Shell)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name: "search",
+			Args: "[words]",
+			Help: "Full-text search live and archived TODOs (word* for prefixes)",
+			Run: func(c *shell.Context) error {
+				query, err := c.Arg("Enter search words: ")
+				if err != nil {
+					return err
+				}
+				search.Run(cfg, workspaceDir, currentWorkspace, query)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name:  "find",
+			Args:  "[query]",
+			Help:  "Find projects by tag, type or age",
+			Usage: "The query is made of the terms tag:<tag> type:<type> stale:<days>.",
+			Run: func(c *shell.Context) error {
+				FindProjects(cfg, c.Rest, currentWorkspace)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name:    "select project",
+			Aliases: []string{"select"},
+			Args:    "[project]",
+			Help:    "Choose a project by number, name or alias to open the Project REPL",
+			Complete: func() []string {
+				var names []string
+				for _, p := range projs.Projects {
+					names = append(names, p.Name)
+					if p.Alias != "" && p.Alias != p.Name {
+						names = append(names, p.Alias)
+					}
+				}
+				return names
+			},
+			Run: func(c *shell.Context) error {
+				selectProject(c, cfg, workspaceDir, projs)
+				return nil
+			},
+		},
+		&shell.Command{
+			Name:    "update projects",
+			Aliases: []string{"update"},
+			Help:    "Scan the workspace for new projects and update the projects.toml file",
+			Run: func(c *shell.Context) error {
+				updatedProjs, err := UpdateProjects(cfg, workspaceDir)
+				if err != nil {
+					return fmt.Errorf("updating projects: %w", err)
+				}
 				projs = updatedProjs
 				fmt.Println("Projects updated successfully!")
-				// Optionally, list the projects.
 				ListProjects(projs)
-			}
-
-		default:
-			fmt.Println("Unknown command. Type 'help' for available commands.")
-		}
-	}
+				return nil
+			},
+		},
+	)
+	sh.PrintHelp()
+	sh.Run()
 }
 
-// printWorkspaceHelp shows commands specific to the workspace-level REPL.
-func printWorkspaceHelp() {
-	fmt.Println(`Available commands (Workspace REPL):
-  help             - Show this help message
-  list projects    - List all projects in this workspace
-  todo             - List aggregated TODOs from all projects in this workspace
-  select project   - Choose a project to open the Project REPL
-  exit             - Exit the Workspace REPL
-  update projects  - Scan the workspace for new projects and update the projects.toml file`)
-}
-
-// selectProject lets the user pick from the loaded Projects, then starts the Project REPL.
-func selectProject(dbPath string, workspaceDir string, projs *Projects, reader *bufio.Reader) {
+// selectProject opens the Project REPL for the project given by number, name
+// or alias as the argument of c, or else lets the user pick from the loaded
+// Projects.
+func selectProject(c *shell.Context, cfg *config.Config, workspaceDir string, projs *Projects) {
 	if projs == nil || len(projs.Projects) == 0 {
 		fmt.Println("No projects found in this workspace.")
 		return
 	}
 
-	fmt.Println("\nSelect a project to open its REPL:")
-	for i, p := range projs.Projects {
-		fmt.Printf("%d) %s (Path: %s)\n", i+1, p.Name, p.Path)
+	input := c.Rest
+	if input == "" {
+		fmt.Println("\nSelect a project to open its REPL:")
+		for i, p := range projs.Projects {
+			fmt.Printf("%d) %s (Path: %s)\n", i+1, p.Name, p.Path)
+		}
 	}
 
 	for {
-		fmt.Print("Enter project number (or 'cancel' to abort): ")
-		input, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Println("Error reading input:", err)
-			return
+		if input == "" {
+			var err error
+			if input, err = c.Shell.Ask("Enter project number (or 'cancel' to abort): "); err != nil {
+				fmt.Println("Error reading input:", err)
+				return
+			}
 		}
-		input = strings.TrimSpace(input)
-
 		if strings.EqualFold(input, "cancel") {
 			return
 		}
 
-		idx, convErr := strconv.Atoi(input)
-		if convErr != nil || idx < 1 || idx > len(projs.Projects) {
+		idx := -1
+		if n, convErr := strconv.Atoi(input); convErr == nil && n >= 1 && n <= len(projs.Projects) {
+			idx = n - 1
+		}
+		for i, p := range projs.Projects {
+			if idx < 0 && (strings.EqualFold(p.Name, input) || (p.Alias != "" && strings.EqualFold(p.Alias, input))) {
+				idx = i
+			}
+		}
+		if idx < 0 {
 			fmt.Println("Invalid project number, try again.")
+			input = ""
 			continue
 		}
 
-		chosenProject := projs.Projects[idx-1]
+		chosenProject := projs.Projects[idx]
 		p := chosenProject.Path
 
 		var projectDir string
@@ -133,7 +191,23 @@ func selectProject(dbPath string, workspaceDir string, projs *Projects, reader *
 
 		fmt.Printf("Selected Project: %s\n", chosenProject.Name)
 		// Start the project-level REPL.
-		project.StartProjectREPL(dbPath, projectDir)
+		project.StartProjectREPL(cfg, projectDir)
 		return
 	}
 }
+
+// browseArchive lets the user search the archived todos of the workspace and
+// restore one into its project.
+func browseArchive(cfg *config.Config, workspaceDir string, sh *shell.Shell) {
+	conn, err := db.InitDB(cfg.DBPath)
+	if err != nil {
+		fmt.Println("Error connecting to db:", err)
+		return
+	}
+	defer conn.Close()
+
+	scope := todo.ArchiveQuery{Workspace: filepath.Base(workspaceDir)}
+	todo.BrowseArchive(conn, sh, scope, func(a todo.ArchivedTodo) (string, error) {
+		return ProjectDir(workspaceDir, a.ProjectName)
+	})
+}
@@ -2,10 +2,17 @@ package workspace
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/johnjallday/flow-workspace/internal/config"
+	"github.com/johnjallday/flow-workspace/internal/db/registry"
+	db "github.com/johnjallday/flow-workspace/internal/db/todo"
+	"github.com/johnjallday/flow-workspace/internal/output"
 	"github.com/johnjallday/flow-workspace/internal/project"
 	"github.com/johnjallday/flow-workspace/internal/todo"
 )
@@ -51,57 +58,40 @@ func SaveProjectsToml(projs *Projects, workspacePath string) error {
 	return nil
 }
 
-// Skip directories and files matching these names
-var skipDirs = map[string]bool{
-	".TagStudio": true,
-	".config":    true,
-}
-
-var skipFiles = map[string]bool{
-	".DS_Store": true,
-}
-
 // UpdateProjects scans the workspace directory for project_info.toml files,
 // aggregates them into a Projects struct, and writes the data to projects.toml.
-// It returns the aggregated Projects pointer or an error.
-func UpdateProjects(workspaceDir string) (*Projects, error) {
+// The projects are also recorded in the workspaces and projects tables of the
+// database of cfg. The directories and files in cfg.SkipDirs are skipped. It
+// returns the aggregated Projects pointer or an error.
+func UpdateProjects(cfg *config.Config, workspaceDir string) (*Projects, error) {
 	var allProjects []project.Project
-
-	// Directories and files to skip.
-	skipDirs := map[string]bool{
-		".TagStudio": true,
-		".config":    true,
-	}
-	skipFiles := map[string]bool{
-		".DS_Store": true,
-	}
+	var records []registry.ProjectRecord
 
 	// Walk the workspace directory to find all project_info.toml files.
 	err := filepath.Walk(workspaceDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			fmt.Printf("Failed to access path %s: %v\n", path, err)
+			log.Printf("Failed to access path %s: %v\n", path, err)
 			return nil // Continue walking.
 		}
 
-		// Skip directories we don't want to descend into.
-		if info.IsDir() && skipDirs[info.Name()] {
-			return filepath.SkipDir
-		}
-
-		// Skip unwanted files.
-		if !info.IsDir() && skipFiles[info.Name()] {
+		// Skip directories we don't want to descend into, and unwanted files.
+		if cfg.Skipped(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
 		// Look for project_info.toml files.
 		if !info.IsDir() && info.Name() == "project_info.toml" {
-			fmt.Printf("Found project_info.toml: %s\n", path)
+			log.Printf("Found project_info.toml: %s\n", path)
 			p, loadErr := project.LoadProjectInfo(path)
 			if loadErr != nil {
-				fmt.Printf("Failed to load project info from '%s': %v\n", path, loadErr)
+				log.Printf("Failed to load project info from '%s': %v\n", path, loadErr)
 				return nil // Continue walking even if one fails.
 			}
 			allProjects = append(allProjects, *p)
+			records = append(records, p.Record(filepath.Dir(path)))
 		}
 
 		return nil
@@ -123,24 +113,33 @@ func UpdateProjects(workspaceDir string) (*Projects, error) {
 	projectsTomlPath := filepath.Join(workspaceDir, "projects.toml")
 
 	// Marshal the aggregated projects into TOML format.
-	output, err := toml.Marshal(aggregated)
+	data, err := toml.Marshal(aggregated)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal projects to TOML: %w", err)
 	}
 
 	// Write the TOML data to the projects.toml file.
-	err = os.WriteFile(projectsTomlPath, output, 0644)
+	err = os.WriteFile(projectsTomlPath, data, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to write to '%s': %w", projectsTomlPath, err)
 	}
 
+	conn, err := db.InitDB(cfg.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to db: %w", err)
+	}
+	defer conn.Close()
+	if err := registry.SaveWorkspace(conn, workspaceDir, records); err != nil {
+		return nil, err
+	}
+
 	return &aggregated, nil
 }
 
 // ScanAndAggregateProjects scans the workspace directory for project_info.toml files
-// and aggregates them into a Projects struct. It ignores the directories .TagStudio, .config,
-// and any file named .DS_Store.
-func ScanAndAggregateProjects(rootDir string) (*Projects, error) {
+// and aggregates them into a Projects struct. It ignores the directories and
+// files in cfg.SkipDirs.
+func ScanAndAggregateProjects(cfg *config.Config, rootDir string) (*Projects, error) {
 	var allProjects []project.Project
 
 	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
@@ -149,13 +148,11 @@ func ScanAndAggregateProjects(rootDir string) (*Projects, error) {
 			return nil // Continue walking
 		}
 
-		// Skip directories we don't want to descend into
-		if info.IsDir() && skipDirs[info.Name()] {
-			return filepath.SkipDir
-		}
-
-		// Skip files we don't want to process
-		if !info.IsDir() && skipFiles[info.Name()] {
+		// Skip directories we don't want to descend into, and files we don't want to process
+		if cfg.Skipped(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -211,19 +208,35 @@ func ListProjects(projs *Projects) {
 	}
 }
 
-func ListAllTodos(workspaceDir string) {
+// ProjectListing returns the projects of projs as a listing for output.Write.
+// The dates are RFC 3339 timestamps.
+func ProjectListing(projs *Projects) *output.Listing {
+	l := output.NewListing("name", "alias", "type", "tags", "notes", "path", "git_url", "todo_store", "date_created", "date_modified")
+	if projs == nil {
+		return l
+	}
+	for _, proj := range projs.Projects {
+		l.Add(proj.Name, proj.Alias, proj.ProjectType, proj.Tags, proj.Notes, proj.Path, proj.GitURL, proj.TodoStore,
+			proj.DateCreated.Format(time.RFC3339), proj.DateModified.Format(time.RFC3339))
+	}
+	return l
+}
+
+// CollectTodos loads the todos of every project listed in the workspace's
+// projects.toml from the project's store, annotating each task with its
+// project and workspace names. The database of cfg is used by projects with
+// the SQLite store.
+func CollectTodos(cfg *config.Config, workspaceDir string) ([]todo.Todo, error) {
 	// Check for the projects.toml in the workspace.
 	projectsTomlPath := filepath.Join(workspaceDir, "projects.toml")
 	if _, err := os.Stat(projectsTomlPath); os.IsNotExist(err) {
-		fmt.Printf("Skipping workspace '%s': no projects.toml found.\n", workspaceDir)
-		return
+		return nil, fmt.Errorf("no projects.toml found")
 	}
 
 	// Load the project entries from projects.toml.
 	projs, err := LoadProjectsToml(workspaceDir)
 	if err != nil {
-		fmt.Printf("Skipping workspace '%s': error loading projects.toml: %v\n", workspaceDir, err)
-		return
+		return nil, fmt.Errorf("error loading projects.toml: %w", err)
 	}
 
 	// Get the workspace name from the directory's base name.
@@ -233,31 +246,21 @@ func ListAllTodos(workspaceDir string) {
 
 	// For each project entry, compute its directory and load its todo.md.
 	for _, proj := range projs.Projects {
-		var projectDir string
-
-		// If a custom path is provided (and is not simply "./"), use it.
-		// Otherwise assume the project is in a folder named after the project.
-		if proj.Path != "" && proj.Path != "./" {
-			if filepath.IsAbs(proj.Path) {
-				projectDir = filepath.Clean(proj.Path)
-			} else {
-				projectDir = filepath.Join(workspaceDir, proj.Path)
-			}
-		} else {
-			projectDir = filepath.Join(workspaceDir, proj.Name)
-		}
+		projectDir := projectDirOf(workspaceDir, proj)
 
-		// Look for the project's todo.md file.
+		// Look for the project's todo.md file unless its tasks live in the database.
 		todoFile := filepath.Join(projectDir, "todo.md")
-		if _, err := os.Stat(todoFile); os.IsNotExist(err) {
-			// Skip if todo.md does not exist.
-			continue
+		if todo.ProjectStore(projectDir) == todo.StoreFile {
+			if _, err := os.Stat(todoFile); os.IsNotExist(err) {
+				// Skip if todo.md does not exist.
+				continue
+			}
 		}
 
-		// Load the tasks from todo.md.
-		tasks, err := todo.LoadAllTodos(todoFile)
+		// Load the tasks from the project's store.
+		tasks, err := todo.LoadProjectTodos(cfg.DBPath, projectDir)
 		if err != nil {
-			fmt.Printf("Error loading todos from '%s': %v\n", todoFile, err)
+			log.Printf("Error loading todos of '%s': %v\n", projectDir, err)
 			continue
 		}
 
@@ -274,11 +277,84 @@ func ListAllTodos(workspaceDir string) {
 		aggregatedTodos = append(aggregatedTodos, tasks...)
 	}
 
+	// Dependencies may cross projects within the workspace.
+	todo.MarkBlocked(aggregatedTodos, aggregatedTodos)
+
+	return aggregatedTodos, nil
+}
+
+// projectDirOf returns the directory of a project listed in the workspace's
+// projects.toml.
+func projectDirOf(workspaceDir string, proj project.Project) string {
+	// If a custom path is provided (and is not simply "./"), use it.
+	// Otherwise assume the project is in a folder named after the project.
+	if proj.Path != "" && proj.Path != "./" {
+		if filepath.IsAbs(proj.Path) {
+			return filepath.Clean(proj.Path)
+		}
+		return filepath.Join(workspaceDir, proj.Path)
+	}
+	return filepath.Join(workspaceDir, proj.Name)
+}
+
+// ProjectDir returns the directory of the project with the given name or
+// alias in the workspace, looking it up in projects.toml first and falling
+// back to a folder named after the project.
+func ProjectDir(workspaceDir string, name string) (string, error) {
+	if projs, err := LoadProjectsToml(workspaceDir); err == nil {
+		for _, proj := range projs.Projects {
+			if strings.EqualFold(proj.Name, name) || (proj.Alias != "" && strings.EqualFold(proj.Alias, name)) {
+				return projectDirOf(workspaceDir, proj), nil
+			}
+		}
+	}
+	projectDir := filepath.Join(workspaceDir, name)
+	if info, err := os.Stat(projectDir); err != nil || !info.IsDir() || name == "" {
+		return "", fmt.Errorf("project '%s' not found in workspace '%s'", name, workspaceDir)
+	}
+	return projectDir, nil
+}
+
+// ListAllTodos prints the aggregated todos of every project in the workspace,
+// sorted by priority, followed by the tasks that are ready to start.
+// If filter is not empty, only matching todos are shown (see todo.FilterTodos).
+func ListAllTodos(cfg *config.Config, workspaceDir string, filter string) {
+	aggregatedTodos, err := CollectTodos(cfg, workspaceDir)
+	if err != nil {
+		fmt.Printf("Skipping workspace '%s': %v\n", workspaceDir, err)
+		return
+	}
+	if filter != "" {
+		aggregatedTodos = todo.FilterTodos(aggregatedTodos, filter)
+	}
+
 	// If no tasks were found, print a message and return.
 	if len(aggregatedTodos) == 0 {
 		fmt.Println("No TODOs found in this workspace.")
 		return
 	}
 
+	// Show the most urgent tasks first.
+	todo.SortByPriority(aggregatedTodos)
+
 	todo.PrintTodos(aggregatedTodos)
+
+	// List the tasks nobody is waiting on that can be picked up next.
+	if ready := todo.ReadyTodos(aggregatedTodos); len(ready) > 0 {
+		fmt.Println("\nReady to start (not blocked):")
+		todo.PrintTodos(ready)
+	}
+}
+
+// FindProjects prints the projects registered by UpdateProjects and
+// ImportProject that match query (see registry.ParseProjectQuery), limited to
+// the workspace named workspace if it is not empty.
+func FindProjects(cfg *config.Config, query string, workspace string) {
+	conn, err := db.InitDB(cfg.DBPath)
+	if err != nil {
+		fmt.Println("Error connecting to db:", err)
+		return
+	}
+	defer conn.Close()
+	registry.Find(conn, query, registry.ProjectQuery{Workspace: workspace})
 }